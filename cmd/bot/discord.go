@@ -0,0 +1,196 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/disgoorg/disgo"
+	disgobot "github.com/disgoorg/disgo/bot"
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/events"
+	"github.com/disgoorg/disgo/gateway"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/rodjunger/nkmonitor/cmd/notify"
+)
+
+// PlatformDiscord is the platform of the products watched from Discord, their chat is the channel id
+const PlatformDiscord = "discord"
+
+// discordMessageLimit is the maximum length of a message content
+const discordMessageLimit = 2000
+
+const notAllowedReply = "You are not allowed to manage the watchlist."
+
+type DiscordOptions struct {
+	Token string
+	// GuildID registers the commands in a single server, where they show up right away. Global commands can take an hour to show up
+	GuildID snowflake.ID
+	// Roles can manage the watchlist, if none are given members with the Manage Server permission can
+	Roles    []snowflake.ID
+	Template *notify.Template
+}
+
+var discordCommands = []discord.ApplicationCommandCreate{
+	discord.SlashCommandCreate{
+		Name:        "watch",
+		Description: "Monitors a product, sending its restocks to this channel",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionString{Name: "url", Description: "Url of the product", Required: true},
+			discord.ApplicationCommandOptionString{Name: "sizes", Description: "Only send restocks of any of these sizes, separated by spaces"},
+		},
+	},
+	discord.SlashCommandCreate{
+		Name:        "unwatch",
+		Description: "Stops sending the restocks of a product to this channel",
+		Options: []discord.ApplicationCommandOption{
+			discord.ApplicationCommandOptionString{Name: "url", Description: "Url of the product", Required: true},
+		},
+	},
+	discord.SlashCommandCreate{
+		Name:        "list",
+		Description: "Lists the products watched in this channel",
+	},
+	discord.SlashCommandCreate{
+		Name:        "status",
+		Description: "Shows what the monitor is doing",
+	},
+}
+
+// DiscordBot manages the watchlist with slash commands
+type DiscordBot struct {
+	client    disgobot.Client
+	watchlist *Watchlist
+	options   DiscordOptions
+}
+
+// NewDiscordBot creates a bot for the watchlist, restocks of the products watched from a channel are sent to it
+func NewDiscordBot(watchlist *Watchlist, options DiscordOptions) (*DiscordBot, error) {
+	if options.Token == "" {
+		return nil, errors.New("empty discord bot token")
+	}
+
+	b := &DiscordBot{watchlist: watchlist, options: options}
+
+	client, err := disgo.New(options.Token,
+		// Slash commands are interactions, they don't need any intent
+		disgobot.WithGatewayConfigOpts(gateway.WithIntents(gateway.IntentsNone)),
+		disgobot.WithEventListenerFunc(b.onCommand),
+	)
+	if err != nil {
+		return nil, err
+	}
+	b.client = client
+
+	watchlist.Register(PlatformDiscord, func(chat string) (notify.Notifyer, error) {
+		channelID, err := snowflake.Parse(chat)
+		if err != nil {
+			return nil, fmt.Errorf("invalid discord channel: %w", err)
+		}
+		notifyer, err := notify.NewDiscordChannelNotifyer(client.Rest(), channelID)
+		if err != nil {
+			return nil, err
+		}
+		if options.Template != nil {
+			notifyer.SetTemplate(options.Template)
+		}
+		return notifyer, nil
+	})
+
+	return b, nil
+}
+
+// Start registers the commands and connects to the gateway
+func (b *DiscordBot) Start(ctx context.Context) error {
+	var err error
+	if b.options.GuildID != 0 {
+		_, err = b.client.Rest().SetGuildCommands(b.client.ApplicationID(), b.options.GuildID, discordCommands)
+	} else {
+		_, err = b.client.Rest().SetGlobalCommands(b.client.ApplicationID(), discordCommands)
+	}
+	if err != nil {
+		return fmt.Errorf("registering commands: %w", err)
+	}
+
+	return b.client.OpenGateway(ctx)
+}
+
+// Close disconnects from the gateway
+func (b *DiscordBot) Close(ctx context.Context) {
+	b.client.Close(ctx)
+}
+
+func (b *DiscordBot) onCommand(e *events.ApplicationCommandInteractionCreate) {
+	data := e.SlashCommandInteractionData()
+
+	invocation := discordInvocation{
+		channelID: e.ChannelID(),
+		user:      e.User().Tag(),
+		member:    e.Member(),
+	}
+
+	reply := b.handle(data.CommandName(), data.String("url"), data.String("sizes"), invocation)
+
+	if err := e.CreateMessage(discord.NewMessageCreateBuilder().SetContent(truncate(reply, discordMessageLimit)).Build()); err != nil {
+		e.Client().Logger().Error("failed to reply to command: ", err)
+	}
+}
+
+// discordInvocation is where and by whom a command was used
+type discordInvocation struct {
+	channelID snowflake.ID
+	user      string
+	member    *discord.ResolvedMember // member is nil in direct messages
+}
+
+// handle runs a command and returns the reply
+func (b *DiscordBot) handle(command, url, sizes string, invocation discordInvocation) string {
+	chat := invocation.channelID.String()
+
+	switch command {
+	case "watch":
+		if !b.canManage(invocation.member) {
+			return notAllowedReply
+		}
+		watch, err := b.watchlist.Watch(url, PlatformDiscord, chat, parseSizes(sizes), invocation.user)
+		if err != nil {
+			return "Can't watch the product: " + err.Error()
+		}
+		return "Watching " + describeWatch(watch)
+	case "unwatch":
+		if !b.canManage(invocation.member) {
+			return notAllowedReply
+		}
+		watch, err := b.watchlist.Unwatch(url, PlatformDiscord, chat)
+		if err != nil {
+			return "Can't unwatch the product: " + err.Error()
+		}
+		return "Stopped watching " + watch.Url
+	case "list":
+		return describeWatches(b.watchlist.List(PlatformDiscord, chat), "No products are watched in this channel.")
+	case "status":
+		return describeStatus(b.watchlist.Status())
+	default:
+		return "Unknown command."
+	}
+}
+
+// canManage reports whether member can watch and unwatch products
+func (b *DiscordBot) canManage(member *discord.ResolvedMember) bool {
+	if member == nil {
+		return false
+	}
+
+	if len(b.options.Roles) == 0 {
+		return member.Permissions.Has(discord.PermissionManageServer) || member.Permissions.Has(discord.PermissionAdministrator)
+	}
+
+	for _, allowed := range b.options.Roles {
+		for _, role := range member.RoleIDs {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
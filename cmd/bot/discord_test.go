@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscordBotCanManage(t *testing.T) {
+	member := func(permissions discord.Permissions, roles ...snowflake.ID) *discord.ResolvedMember {
+		return &discord.ResolvedMember{Member: discord.Member{RoleIDs: roles}, Permissions: permissions}
+	}
+
+	t.Run("WithoutRoles", func(t *testing.T) {
+		b := &DiscordBot{}
+		assert.True(t, b.canManage(member(discord.PermissionManageServer)))
+		assert.True(t, b.canManage(member(discord.PermissionAdministrator)))
+		assert.False(t, b.canManage(member(discord.PermissionSendMessages)))
+		assert.False(t, b.canManage(nil), "direct messages can't manage the watchlist")
+	})
+
+	t.Run("WithRoles", func(t *testing.T) {
+		b := &DiscordBot{options: DiscordOptions{Roles: []snowflake.ID{1, 2}}}
+		assert.True(t, b.canManage(member(0, 3, 2)))
+		assert.False(t, b.canManage(member(discord.PermissionAdministrator, 3)), "only the roles given can manage the watchlist")
+	})
+}
+
+func TestDiscordBotHandle(t *testing.T) {
+	watchlist, _, _ := newTestWatchlist(t)
+	watchlist.Register(PlatformDiscord, (&chatRecorder{}).factory)
+	b := &DiscordBot{watchlist: watchlist}
+
+	admin := discordInvocation{
+		channelID: 10,
+		user:      "admin#0001",
+		member:    &discord.ResolvedMember{Permissions: discord.PermissionAdministrator},
+	}
+	member := discordInvocation{
+		channelID: 10,
+		user:      "member#0001",
+		member:    &discord.ResolvedMember{},
+	}
+
+	assert.Equal(t, "No products are watched in this channel.", b.handle("list", "", "", member))
+
+	assert.Equal(t, notAllowedReply, b.handle("watch", testUrl, "", member))
+	assert.Equal(t, "Watching https://www.nike.com.br/tenis/test.html (sizes 42, 43)", b.handle("watch", testUrl, "42 43", admin))
+	assert.Equal(t, "Can't watch the product: product is already watched in this chat", b.handle("watch", testUrl, "", admin))
+	assert.Equal(t, "Can't watch the product: invalid URL", b.handle("watch", "https://example.com/a", "", admin))
+
+	assert.Equal(t, "• https://www.nike.com.br/tenis/test.html (sizes 42, 43)", b.handle("list", "", "", member))
+	assert.Contains(t, b.handle("status", "", "", member), "Monitoring 1 products in 1 watches")
+
+	assert.Equal(t, notAllowedReply, b.handle("unwatch", testUrl, "", member))
+	assert.Equal(t, "Stopped watching https://www.nike.com.br/tenis/test.html", b.handle("unwatch", testUrl, "", admin))
+	assert.Equal(t, "Can't unwatch the product: product is not watched in this chat", b.handle("unwatch", testUrl, "", admin))
+}
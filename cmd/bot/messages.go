@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// parseSizes splits a list of sizes separated by spaces, like "40 41 35,5". Commas are part of the sizes
+func parseSizes(sizes string) []string {
+	parsed := strings.Fields(sizes)
+	if len(parsed) == 0 {
+		return nil
+	}
+	return parsed
+}
+
+// describeWatch formats a watch as a line of the replies of the bots
+func describeWatch(watch Watch) string {
+	if len(watch.Sizes) == 0 {
+		return watch.Url
+	}
+	return fmt.Sprintf("%s (sizes %s)", watch.Url, strings.Join(watch.Sizes, ", "))
+}
+
+// describeWatches formats watches as the reply of the list commands, empty is used if there are none
+func describeWatches(watches []Watch, empty string) string {
+	if len(watches) == 0 {
+		return empty
+	}
+	lines := make([]string, len(watches))
+	for i, watch := range watches {
+		lines[i] = "• " + describeWatch(watch)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// describeStatus formats a status as the reply of the status command
+func describeStatus(status Status) string {
+	return fmt.Sprintf("Monitoring %d products in %d watches, up for %s.\nProxies: %d, %d benched.\nSuppressed restocks: %d unstable, %d in product cooldown, %d in size cooldown.",
+		status.Products, status.Watches, time.Since(status.Since).Round(time.Second),
+		status.Proxies, status.BenchedProxies,
+		status.Suppressed.Unstable, status.Suppressed.ProductCooldown, status.Suppressed.SizeCooldown)
+}
+
+// truncate cuts text to limit bytes, ending it with an ellipsis when cut
+func truncate(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	cut := text[:limit-len("…")]
+	// Don't leave half of a multi-byte character
+	for len(cut) > 0 && !utf8.ValidString(cut) {
+		cut = cut[:len(cut)-1]
+	}
+	return cut + "…"
+}
@@ -0,0 +1,254 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodjunger/nkmonitor/cmd/notify"
+)
+
+// PlatformTelegram is the platform of the products watched from Telegram, their chat is the chat id
+const PlatformTelegram = "telegram"
+
+const (
+	// telegramMessageLimit is the maximum length of a message text
+	telegramMessageLimit = 4096
+	// telegramPollTimeout is how long, in seconds, getUpdates waits for updates before returning none
+	telegramPollTimeout = 30
+	// telegramRetryDelay is the wait after failing to get updates
+	telegramRetryDelay = 5 * time.Second
+)
+
+const telegramHelp = `Every chat has its own watchlist, restocks of the products watched are sent to it.
+
+/watch url [sizes] - monitors a product, only sending restocks where any of the sizes restocked when they are given
+/unwatch url - stops monitoring a product
+/sizes url [sizes] - changes the sizes of a product, only restocks where any of them restocked are sent, every restock if none are given
+/list - lists the products watched`
+
+type TelegramOptions struct {
+	Token string
+	// ApiUrl is the Bot API base URL, the official one is used if it's empty
+	ApiUrl   string
+	Template *notify.Template
+	// AllowedChats are the ids of the chats that can use the commands, every chat can if it's empty
+	AllowedChats []string
+	// MaxWatches is how many products a chat can watch, 0 doesn't limit them
+	MaxWatches int
+	// OnError is called when updates can't be received or a reply can't be sent
+	OnError func(err error)
+}
+
+// TelegramBot manages a watchlist for every chat with commands
+type TelegramBot struct {
+	watchlist *Watchlist
+	options   TelegramOptions
+	client    *http.Client
+	cancel    context.CancelFunc
+	stopped   chan struct{}
+}
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		From *struct {
+			ID        int64  `json:"id"`
+			Username  string `json:"username"`
+			FirstName string `json:"first_name"`
+		} `json:"from"`
+	} `json:"message"`
+}
+
+// NewTelegramBot creates a bot for the watchlist, restocks of the products watched from a chat are sent to it
+func NewTelegramBot(watchlist *Watchlist, options TelegramOptions) (*TelegramBot, error) {
+	if options.Token == "" {
+		return nil, errors.New("empty telegram bot token")
+	}
+
+	if options.ApiUrl == "" {
+		options.ApiUrl = notify.DefaultTelegramApiUrl
+	}
+	options.ApiUrl = strings.TrimSuffix(options.ApiUrl, "/")
+
+	watchlist.Register(PlatformTelegram, func(chat string) (notify.Notifyer, error) {
+		notifyer, err := notify.NewTelegramNotifyer(options.Token, []string{chat}, options.ApiUrl)
+		if err != nil {
+			return nil, err
+		}
+		if options.Template != nil {
+			notifyer.SetTemplate(options.Template)
+		}
+		return notifyer, nil
+	})
+
+	return &TelegramBot{
+		watchlist: watchlist,
+		options:   options,
+		client:    &http.Client{Timeout: (telegramPollTimeout + 10) * time.Second},
+	}, nil
+}
+
+// Start starts receiving commands in the background
+func (b *TelegramBot) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	b.stopped = make(chan struct{})
+	go b.run(ctx)
+}
+
+// Close stops receiving commands
+func (b *TelegramBot) Close() {
+	if b.cancel == nil {
+		return
+	}
+	b.cancel()
+	<-b.stopped
+}
+
+func (b *TelegramBot) run(ctx context.Context) {
+	defer close(b.stopped)
+
+	var offset int64
+	for {
+		var updates []telegramUpdate
+		err := b.call(ctx, "getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         telegramPollTimeout,
+			"allowed_updates": []string{"message"},
+		}, &updates)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			b.onError(err)
+			select {
+			case <-time.After(telegramRetryDelay):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+
+			message := update.Message
+			if message == nil || !strings.HasPrefix(message.Text, "/") {
+				continue
+			}
+
+			user := ""
+			if message.From != nil {
+				switch {
+				case message.From.Username != "":
+					user = "@" + message.From.Username
+				case message.From.FirstName != "":
+					user = message.From.FirstName
+				default:
+					user = strconv.FormatInt(message.From.ID, 10)
+				}
+			}
+
+			chat := strconv.FormatInt(message.Chat.ID, 10)
+			reply := b.handle(chat, user, message.Text)
+			if err := b.call(ctx, "sendMessage", map[string]interface{}{
+				"chat_id":                  chat,
+				"text":                     truncate(reply, telegramMessageLimit),
+				"disable_web_page_preview": true,
+			}, nil); err != nil {
+				b.onError(err)
+			}
+		}
+	}
+}
+
+func (b *TelegramBot) onError(err error) {
+	if b.options.OnError != nil {
+		b.options.OnError(err)
+	}
+}
+
+// call calls a Bot API method with a JSON payload, decoding its result into result if it isn't nil
+func (b *TelegramBot) call(ctx context.Context, method string, payload map[string]interface{}, result interface{}) error {
+	return notify.TelegramCall(ctx, b.client, b.options.ApiUrl, b.options.Token, method, payload, result)
+}
+
+// allowed reports whether chat can use the commands
+func (b *TelegramBot) allowed(chat string) bool {
+	if len(b.options.AllowedChats) == 0 {
+		return true
+	}
+	for _, allowed := range b.options.AllowedChats {
+		if allowed == chat {
+			return true
+		}
+	}
+	return false
+}
+
+// handle runs a command sent to chat and returns the reply
+func (b *TelegramBot) handle(chat, user, text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return telegramHelp
+	}
+	// Commands are sent as /command@bot in groups
+	command := strings.SplitN(fields[0], "@", 2)[0]
+	args := fields[1:]
+
+	// The id is given so the chat can be allowed
+	if command != "/start" && command != "/help" && !b.allowed(chat) {
+		return "This chat is not allowed to use the bot, its id is " + chat + "."
+	}
+
+	switch command {
+	case "/start", "/help":
+		return telegramHelp
+	case "/watch":
+		if len(args) == 0 {
+			return "Usage: /watch url [sizes]"
+		}
+		if b.options.MaxWatches > 0 && len(b.watchlist.List(PlatformTelegram, chat)) >= b.options.MaxWatches {
+			return fmt.Sprintf("Can't watch the product: this chat already watches %d products, the most it can.", b.options.MaxWatches)
+		}
+		watch, err := b.watchlist.Watch(args[0], PlatformTelegram, chat, parseSizes(strings.Join(args[1:], " ")), user)
+		if err != nil {
+			return "Can't watch the product: " + err.Error()
+		}
+		return "Watching " + describeWatch(watch)
+	case "/unwatch":
+		if len(args) == 0 {
+			return "Usage: /unwatch url"
+		}
+		watch, err := b.watchlist.Unwatch(args[0], PlatformTelegram, chat)
+		if err != nil {
+			return "Can't unwatch the product: " + err.Error()
+		}
+		return "Stopped watching " + watch.Url
+	case "/sizes":
+		if len(args) == 0 {
+			return "Usage: /sizes url [sizes]"
+		}
+		watch, err := b.watchlist.SetSizes(args[0], PlatformTelegram, chat, parseSizes(strings.Join(args[1:], " ")))
+		if err != nil {
+			return "Can't change the sizes: " + err.Error()
+		}
+		if len(watch.Sizes) == 0 {
+			return "Sending every restock of " + watch.Url
+		}
+		return "Watching " + describeWatch(watch)
+	case "/list":
+		return describeWatches(b.watchlist.List(PlatformTelegram, chat), "No products are watched in this chat.")
+	default:
+		return "Unknown command, see /help."
+	}
+}
@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTelegramBotHandle(t *testing.T) {
+	watchlist, _, _ := newTestWatchlist(t)
+	b, err := NewTelegramBot(watchlist, TelegramOptions{Token: "token"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, telegramHelp, b.handle("1", "@someone", "/start"))
+	assert.Equal(t, "No products are watched in this chat.", b.handle("1", "@someone", "/list"))
+
+	assert.Equal(t, "Usage: /watch url [sizes]", b.handle("1", "@someone", "/watch"))
+	assert.Equal(t, "Watching https://www.nike.com.br/tenis/test.html (sizes 42, 35,5)", b.handle("1", "@someone", "/watch "+testUrl+" 42 35,5"))
+	assert.Equal(t, "Can't watch the product: product is already watched in this chat", b.handle("1", "@someone", "/watch@nkbot "+testUrl))
+	assert.Equal(t, "No products are watched in this chat.", b.handle("2", "@other", "/list"), "every chat should have its own watchlist")
+
+	assert.Equal(t, "Watching https://www.nike.com.br/tenis/test.html (sizes 40)", b.handle("1", "@someone", "/sizes "+testUrl+" 40"))
+	assert.Equal(t, "• https://www.nike.com.br/tenis/test.html (sizes 40)", b.handle("1", "@someone", "/list"))
+	assert.Equal(t, "Sending every restock of https://www.nike.com.br/tenis/test.html", b.handle("1", "@someone", "/sizes "+testUrl))
+	assert.Equal(t, "Can't change the sizes: product is not watched in this chat", b.handle("2", "@other", "/sizes "+testUrl+" 40"))
+
+	assert.Equal(t, "Stopped watching https://www.nike.com.br/tenis/test.html", b.handle("1", "@someone", "/unwatch "+testUrl))
+	assert.Equal(t, "Can't unwatch the product: product is not watched in this chat", b.handle("1", "@someone", "/unwatch "+testUrl))
+
+	assert.Equal(t, "Unknown command, see /help.", b.handle("1", "@someone", "/buy"))
+}
+
+func TestTelegramBotLimits(t *testing.T) {
+	t.Run("WithAllowedChats", func(t *testing.T) {
+		watchlist, _, _ := newTestWatchlist(t)
+		b, err := NewTelegramBot(watchlist, TelegramOptions{Token: "token", AllowedChats: []string{"1"}})
+		assert.NoError(t, err)
+
+		assert.Equal(t, "Watching https://www.nike.com.br/tenis/test.html", b.handle("1", "@someone", "/watch "+testUrl))
+		assert.Equal(t, "This chat is not allowed to use the bot, its id is 2.", b.handle("2", "@other", "/watch "+testUrl))
+		assert.Equal(t, telegramHelp, b.handle("2", "@other", "/help"), "help should be sent to every chat")
+		assert.Empty(t, watchlist.List(PlatformTelegram, "2"))
+	})
+
+	t.Run("WithMaxWatches", func(t *testing.T) {
+		watchlist, _, _ := newTestWatchlist(t)
+		b, err := NewTelegramBot(watchlist, TelegramOptions{Token: "token", MaxWatches: 1})
+		assert.NoError(t, err)
+
+		assert.Equal(t, "Watching https://www.nike.com.br/tenis/test.html", b.handle("1", "@someone", "/watch "+testUrl))
+		assert.Equal(t, "Can't watch the product: this chat already watches 1 products, the most it can.", b.handle("1", "@someone", "/watch https://www.nike.com.br/tenis/other.html"))
+		assert.Equal(t, "Watching https://www.nike.com.br/tenis/test.html", b.handle("2", "@other", "/watch "+testUrl), "the limit should be per chat")
+	})
+}
+
+func TestTelegramBotRun(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		polled  int
+		replies []map[string]interface{}
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.URL.Path {
+		case "/bottoken/getUpdates":
+			polled++
+			if polled == 1 {
+				w.Write([]byte(`{"ok":true,"result":[
+					{"update_id":7,"message":{"text":"/watch ` + testUrl + `","chat":{"id":42},"from":{"id":1,"username":"someone"}}},
+					{"update_id":8,"message":{"text":"hello","chat":{"id":42}}}
+				]}`))
+				return
+			}
+			assert.Equal(t, float64(9), payload["offset"], "updates should be confirmed")
+			w.Write([]byte(`{"ok":true,"result":[]}`))
+		case "/bottoken/sendMessage":
+			replies = append(replies, payload)
+			w.Write([]byte(`{"ok":true,"result":{}}`))
+		default:
+			t.Errorf("unexpected method %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	watchlist, _, _ := newTestWatchlist(t)
+	b, err := NewTelegramBot(watchlist, TelegramOptions{Token: "token", ApiUrl: server.URL, OnError: func(err error) {
+		t.Errorf("unexpected error: %v", err)
+	}})
+	assert.NoError(t, err)
+
+	b.Start()
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return polled > 1
+	}, time.Second, 10*time.Millisecond)
+	b.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, replies, 1, "only commands should be replied") {
+		assert.Equal(t, "42", replies[0]["chat_id"])
+		assert.Equal(t, "Watching https://www.nike.com.br/tenis/test.html", replies[0]["text"])
+	}
+
+	if watches := watchlist.List(PlatformTelegram, "42"); assert.Len(t, watches, 1) {
+		assert.Equal(t, "@someone", watches[0].AddedBy)
+	}
+}
@@ -0,0 +1,432 @@
+// Package bot lets chat users manage the products being monitored, sending their restocks back to the chat they were watched from
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/rodjunger/nkmonitor/cmd/notify"
+)
+
+var (
+	ErrAlreadyWatched  = errors.New("product is already watched in this chat")
+	ErrNotWatched      = errors.New("product is not watched in this chat")
+	ErrUnknownPlatform = errors.New("unknown platform")
+)
+
+// Monitor is the part of nkmonitor.Monitor used by the bots
+type Monitor interface {
+	AddTask(productUrl string, callback chan nkmonitor.RestockInfo, opts ...nkmonitor.TaskOption) (string, error)
+	RemoveTask(taskId string)
+	ProxyStats() []nkmonitor.ProxyStats
+	DedupeStats() nkmonitor.DedupeStats
+}
+
+// Watch is a product watched from a chat
+type Watch struct {
+	TaskID   string    `json:"-"` // TaskID is the id of the monitor task, it changes on every run
+	Url      string    `json:"url"`
+	Path     string    `json:"path"`
+	Platform string    `json:"platform"` // Platform is the bot the product was watched from, like discord
+	Chat     string    `json:"chat"`     // Chat is where restocks are sent, its format depends on the platform
+	Sizes    []string  `json:"sizes"`    // Sizes restrict the restocks sent to the ones where any of them restocked
+	AddedBy  string    `json:"addedBy"`
+	AddedAt  time.Time `json:"addedAt"`
+}
+
+// matches reports whether any of the sizes of the watch restocked in info, sizes that were already in stock don't count.
+// Watches without sizes get every restock
+func (w Watch) matches(info nkmonitor.RestockInfo) bool {
+	return len(w.Sizes) == 0 || notify.RestockedAny(info, w.Sizes)
+}
+
+// NotifyerFactory creates the notifyer of a chat
+type NotifyerFactory func(chat string) (notify.Notifyer, error)
+
+// Status is a summary of what the monitor is doing
+type Status struct {
+	Watches        int
+	Products       int
+	Proxies        int
+	BenchedProxies int
+	Suppressed     nkmonitor.DedupeStats
+	Since          time.Time
+}
+
+type WatchlistOptions struct {
+	// Wrap is applied to every notifyer created, used to put a queue in front of them
+	Wrap func(name string, notifyer notify.Notifyer) notify.Notifyer
+	// File is the JSON file the watches are saved to on every change, they aren't saved if it's empty
+	File string
+	// OnError is called when a restock can't be sent to a chat or a watch can't be saved or restored
+	OnError func(watch Watch, err error)
+}
+
+// Watchlist adds and removes monitor tasks for the products watched from chats and routes their restocks back
+type Watchlist struct {
+	monitor   Monitor
+	options   WatchlistOptions
+	restocks  chan nkmonitor.RestockInfo
+	done      chan struct{}
+	startedAt time.Time
+
+	mu        sync.Mutex
+	watches   map[string]*Watch // watches by task id
+	pending   []Watch           // pending are the saved watches that couldn't be restored, kept so they aren't lost
+	factories map[string]NotifyerFactory
+	notifyers map[string]notify.Notifyer // notifyers by platform and chat
+}
+
+// NewWatchlist creates a watchlist adding tasks to monitor, which must be started
+func NewWatchlist(monitor Monitor, options WatchlistOptions) *Watchlist {
+	w := &Watchlist{
+		monitor:   monitor,
+		options:   options,
+		restocks:  make(chan nkmonitor.RestockInfo),
+		done:      make(chan struct{}),
+		startedAt: time.Now(),
+		watches:   map[string]*Watch{},
+		factories: map[string]NotifyerFactory{},
+		notifyers: map[string]notify.Notifyer{},
+	}
+	go w.run()
+	return w
+}
+
+// Register sets how the notifyers of the chats of a platform are created, must be called before watching from it
+func (w *Watchlist) Register(platform string, factory NotifyerFactory) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.factories[platform] = factory
+}
+
+// Watch starts monitoring productUrl and sending its restocks to chat
+func (w *Watchlist) Watch(productUrl, platform, chat string, sizes []string, addedBy string) (Watch, error) {
+	parsed, err := nkmonitor.ParseNKUrl(productUrl)
+	if err != nil {
+		return Watch{}, err
+	}
+
+	watch := Watch{
+		Url:      "https://www.nike.com.br" + parsed.Path,
+		Path:     parsed.Path,
+		Platform: platform,
+		Chat:     chat,
+		Sizes:    sizes,
+		AddedBy:  addedBy,
+		AddedAt:  time.Now(),
+	}
+
+	if err := w.add(&watch); err != nil {
+		return Watch{}, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.save(watch)
+
+	return watch, nil
+}
+
+// add creates the task of watch, without saving it
+func (w *Watchlist) add(watch *Watch) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.find(watch.Path, watch.Platform, watch.Chat) != nil {
+		return ErrAlreadyWatched
+	}
+
+	if _, err := w.notifyer(watch.Platform, watch.Chat); err != nil {
+		return err
+	}
+
+	taskID, err := w.monitor.AddTask(watch.Url, w.restocks)
+	if err != nil {
+		return err
+	}
+
+	watch.TaskID = taskID
+	w.watches[taskID] = watch
+
+	// A watch that couldn't be restored is replaced by the new one
+	pending := w.pending[:0]
+	for _, saved := range w.pending {
+		if saved.Path != watch.Path || saved.Platform != watch.Platform || saved.Chat != watch.Chat {
+			pending = append(pending, saved)
+		}
+	}
+	w.pending = pending
+
+	return nil
+}
+
+// Unwatch stops sending the restocks of productUrl to chat, the product stops being monitored if nobody else watches it
+func (w *Watchlist) Unwatch(productUrl, platform, chat string) (Watch, error) {
+	parsed, err := nkmonitor.ParseNKUrl(productUrl)
+	if err != nil {
+		return Watch{}, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	watch := w.find(parsed.Path, platform, chat)
+	if watch == nil {
+		return Watch{}, ErrNotWatched
+	}
+
+	w.monitor.RemoveTask(watch.TaskID)
+	delete(w.watches, watch.TaskID)
+	w.save(*watch)
+
+	return *watch, nil
+}
+
+// SetSizes changes the sizes of productUrl watched from chat, no sizes sends every restock
+func (w *Watchlist) SetSizes(productUrl, platform, chat string, sizes []string) (Watch, error) {
+	parsed, err := nkmonitor.ParseNKUrl(productUrl)
+	if err != nil {
+		return Watch{}, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	watch := w.find(parsed.Path, platform, chat)
+	if watch == nil {
+		return Watch{}, ErrNotWatched
+	}
+
+	watch.Sizes = sizes
+	w.save(*watch)
+
+	return *watch, nil
+}
+
+// Load restores the watches saved to the file, which may not exist yet. The platforms must be registered before,
+// watches of other platforms are kept in the file for when they are
+func (w *Watchlist) Load() error {
+	if w.options.File == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(w.options.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var saved []Watch
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("%s: %w", w.options.File, err)
+	}
+
+	for _, watch := range saved {
+		watch := watch
+		if err := w.add(&watch); err != nil {
+			if w.options.OnError != nil {
+				w.options.OnError(watch, fmt.Errorf("restoring watch: %w", err))
+			}
+			w.mu.Lock()
+			w.pending = append(w.pending, watch)
+			w.mu.Unlock()
+		}
+	}
+
+	return nil
+}
+
+// List returns the products watched from chat, by the time they were added
+func (w *Watchlist) List(platform, chat string) []Watch {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var watches []Watch
+	for _, watch := range w.watches {
+		if watch.Platform == platform && watch.Chat == chat {
+			watches = append(watches, *watch)
+		}
+	}
+	sortWatches(watches)
+
+	return watches
+}
+
+// All returns every watched product, by the time they were added
+func (w *Watchlist) All() []Watch {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	watches := make([]Watch, 0, len(w.watches))
+	for _, watch := range w.watches {
+		watches = append(watches, *watch)
+	}
+	sortWatches(watches)
+
+	return watches
+}
+
+func sortWatches(watches []Watch) {
+	sort.SliceStable(watches, func(i, j int) bool {
+		return watches[i].AddedAt.Before(watches[j].AddedAt)
+	})
+}
+
+// Status returns a summary of the watchlist and the monitor
+func (w *Watchlist) Status() Status {
+	status := Status{Suppressed: w.monitor.DedupeStats(), Since: w.startedAt}
+
+	now := time.Now()
+	for _, proxy := range w.monitor.ProxyStats() {
+		status.Proxies++
+		if now.Before(proxy.BenchedUntil) {
+			status.BenchedProxies++
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	products := map[string]bool{}
+	for _, watch := range w.watches {
+		products[watch.Path] = true
+	}
+	status.Watches = len(w.watches)
+	status.Products = len(products)
+
+	return status
+}
+
+// Close stops sending restocks and closes the notifyers of the chats, the tasks are left to be stopped with the monitor
+func (w *Watchlist) Close() {
+	close(w.done)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, notifyer := range w.notifyers {
+		if closer, ok := notifyer.(interface{ Close() }); ok {
+			closer.Close()
+		}
+	}
+}
+
+// save writes every watch to the file, changed is the watch that was changed. Must be called with mu locked
+func (w *Watchlist) save(changed Watch) {
+	if w.options.File == "" {
+		return
+	}
+
+	watches := append([]Watch{}, w.pending...)
+	for _, watch := range w.watches {
+		watches = append(watches, *watch)
+	}
+	sortWatches(watches)
+
+	if err := writeWatches(w.options.File, watches); err != nil && w.options.OnError != nil {
+		w.options.OnError(changed, fmt.Errorf("saving watchlist: %w", err))
+	}
+}
+
+// writeWatches replaces the file at path, through a temporary file so it's never left half written
+func writeWatches(path string, watches []Watch) error {
+	data, err := json.MarshalIndent(watches, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// find returns the watch of path in chat, must be called with mu locked
+func (w *Watchlist) find(path, platform, chat string) *Watch {
+	for _, watch := range w.watches {
+		if watch.Path == path && watch.Platform == platform && watch.Chat == chat {
+			return watch
+		}
+	}
+	return nil
+}
+
+// notifyer returns the notifyer of chat, creating it on the first use. Must be called with mu locked
+func (w *Watchlist) notifyer(platform, chat string) (notify.Notifyer, error) {
+	key := platform + ":" + chat
+	if notifyer, ok := w.notifyers[key]; ok {
+		return notifyer, nil
+	}
+
+	factory, ok := w.factories[platform]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownPlatform, platform)
+	}
+
+	notifyer, err := factory(chat)
+	if err != nil {
+		return nil, err
+	}
+	if w.options.Wrap != nil {
+		notifyer = w.options.Wrap(key, notifyer)
+	}
+	w.notifyers[key] = notifyer
+
+	return notifyer, nil
+}
+
+func (w *Watchlist) run() {
+	for {
+		select {
+		case info := <-w.restocks:
+			w.dispatch(info)
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// dispatch sends info to the chat of its task if it has any of the sizes watched
+func (w *Watchlist) dispatch(info nkmonitor.RestockInfo) {
+	w.mu.Lock()
+	found, ok := w.watches[info.TaskID]
+	if !ok {
+		w.mu.Unlock()
+		return
+	}
+	watch := *found
+	notifyer := w.notifyers[watch.Platform+":"+watch.Chat]
+	w.mu.Unlock()
+
+	if !watch.matches(info) {
+		return
+	}
+
+	go func() {
+		if err := notifyer.Notify(info); err != nil && w.options.OnError != nil {
+			w.options.OnError(watch, err)
+		}
+	}()
+}
@@ -0,0 +1,320 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/rodjunger/nkmonitor/cmd/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMonitor keeps the tasks added and sends restocks to their callbacks
+type fakeMonitor struct {
+	mu     sync.Mutex
+	tasks  map[string]string // tasks maps task ids to paths
+	cbs    map[string]chan nkmonitor.RestockInfo
+	nextID int
+	err    error
+}
+
+func newFakeMonitor() *fakeMonitor {
+	return &fakeMonitor{tasks: map[string]string{}, cbs: map[string]chan nkmonitor.RestockInfo{}}
+}
+
+func (f *fakeMonitor) AddTask(productUrl string, callback chan nkmonitor.RestockInfo, opts ...nkmonitor.TaskOption) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return "", f.err
+	}
+	parsed, err := nkmonitor.ParseNKUrl(productUrl)
+	if err != nil {
+		return "", err
+	}
+	f.nextID++
+	id := strconv.Itoa(f.nextID)
+	f.tasks[id] = parsed.Path
+	f.cbs[id] = callback
+	return id, nil
+}
+
+func (f *fakeMonitor) RemoveTask(taskId string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.tasks, taskId)
+	delete(f.cbs, taskId)
+}
+
+func (f *fakeMonitor) ProxyStats() []nkmonitor.ProxyStats {
+	return []nkmonitor.ProxyStats{{Proxy: "a"}, {Proxy: "b", BenchedUntil: time.Now().Add(time.Hour)}}
+}
+
+func (f *fakeMonitor) DedupeStats() nkmonitor.DedupeStats {
+	return nkmonitor.DedupeStats{Unstable: 3}
+}
+
+// restock sends a restock of path to the callback of every task of it, like the monitor does
+func (f *fakeMonitor) restock(info nkmonitor.RestockInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, path := range f.tasks {
+		if path == info.Path {
+			info.TaskID = id
+			f.cbs[id] <- info
+		}
+	}
+}
+
+// chatRecorder creates notifyers recording the restocks sent to every chat
+type chatRecorder struct {
+	mu       sync.Mutex
+	restocks map[string][]nkmonitor.RestockInfo
+}
+
+type chatNotifyer struct {
+	recorder *chatRecorder
+	chat     string
+}
+
+func (c chatNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	c.recorder.mu.Lock()
+	defer c.recorder.mu.Unlock()
+	c.recorder.restocks[c.chat] = append(c.recorder.restocks[c.chat], info)
+	return nil
+}
+
+func (c *chatRecorder) factory(chat string) (notify.Notifyer, error) {
+	return chatNotifyer{recorder: c, chat: chat}, nil
+}
+
+func (c *chatRecorder) count(chat string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.restocks[chat])
+}
+
+func newTestWatchlist(t *testing.T) (*Watchlist, *fakeMonitor, *chatRecorder) {
+	monitor := newFakeMonitor()
+	recorder := &chatRecorder{restocks: map[string][]nkmonitor.RestockInfo{}}
+	watchlist := NewWatchlist(monitor, WatchlistOptions{})
+	watchlist.Register("test", recorder.factory)
+	t.Cleanup(watchlist.Close)
+	return watchlist, monitor, recorder
+}
+
+const testUrl = "https://www.nike.com.br/tenis/test.html?cor=ND"
+
+func TestWatchlist(t *testing.T) {
+	t.Run("WithWatchAndUnwatch", func(t *testing.T) {
+		watchlist, monitor, _ := newTestWatchlist(t)
+
+		watch, err := watchlist.Watch(testUrl, "test", "1", []string{"42"}, "someone")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://www.nike.com.br/tenis/test.html", watch.Url)
+		assert.Equal(t, "/tenis/test.html", watch.Path)
+		assert.Len(t, monitor.tasks, 1)
+
+		_, err = watchlist.Watch(testUrl, "test", "1", nil, "someone")
+		assert.ErrorIs(t, err, ErrAlreadyWatched)
+
+		_, err = watchlist.Watch(testUrl, "test", "2", nil, "someone")
+		assert.NoError(t, err, "other chats can watch the same product")
+
+		assert.Len(t, watchlist.List("test", "1"), 1)
+		assert.Len(t, watchlist.All(), 2)
+
+		_, err = watchlist.Unwatch(testUrl, "test", "1")
+		assert.NoError(t, err)
+		_, err = watchlist.Unwatch(testUrl, "test", "1")
+		assert.ErrorIs(t, err, ErrNotWatched)
+
+		assert.Empty(t, watchlist.List("test", "1"))
+		assert.Len(t, monitor.tasks, 1)
+	})
+
+	t.Run("WithInvalidWatch", func(t *testing.T) {
+		watchlist, monitor, _ := newTestWatchlist(t)
+
+		_, err := watchlist.Watch("https://www.youtube.com/watch", "test", "1", nil, "someone")
+		assert.ErrorIs(t, err, nkmonitor.ErrInvalidUrl)
+
+		_, err = watchlist.Watch(testUrl, "other", "1", nil, "someone")
+		assert.ErrorIs(t, err, ErrUnknownPlatform)
+
+		monitor.err = nkmonitor.ErrTooManyTasks
+		_, err = watchlist.Watch(testUrl, "test", "1", nil, "someone")
+		assert.ErrorIs(t, err, nkmonitor.ErrTooManyTasks)
+
+		assert.Empty(t, watchlist.All())
+	})
+
+	t.Run("WithRestocks", func(t *testing.T) {
+		watchlist, monitor, recorder := newTestWatchlist(t)
+
+		_, err := watchlist.Watch(testUrl, "test", "any", nil, "someone")
+		assert.NoError(t, err)
+		_, err = watchlist.Watch(testUrl, "test", "43", []string{"43"}, "someone")
+		assert.NoError(t, err)
+		_, err = watchlist.Watch(testUrl, "test", "44", []string{"44"}, "someone")
+		assert.NoError(t, err)
+
+		monitor.restock(nkmonitor.RestockInfo{
+			Path: "/tenis/test.html",
+			Sizes: []*nkmonitor.SizeInfo{
+				{Description: "43", HasStock: true, Restocked: true},
+				{Description: "44", HasStock: true},
+			},
+		})
+
+		assert.Eventually(t, func() bool {
+			return recorder.count("any") == 1 && recorder.count("43") == 1
+		}, time.Second, 10*time.Millisecond)
+		assert.Zero(t, recorder.count("44"), "restocks of other sizes should not be sent, even with the sizes watched in stock")
+	})
+
+	t.Run("WithSizesChanged", func(t *testing.T) {
+		watchlist, monitor, recorder := newTestWatchlist(t)
+
+		_, err := watchlist.Watch(testUrl, "test", "1", nil, "someone")
+		assert.NoError(t, err)
+		_, err = watchlist.SetSizes(testUrl, "test", "1", []string{"44"})
+		assert.NoError(t, err)
+
+		monitor.restock(nkmonitor.RestockInfo{
+			Path: "/tenis/test.html",
+			Sizes: []*nkmonitor.SizeInfo{
+				{Description: "43", HasStock: true, Restocked: true},
+				{Description: "44", HasStock: true},
+			},
+		})
+		monitor.restock(nkmonitor.RestockInfo{
+			Path:  "/tenis/test.html",
+			Sizes: []*nkmonitor.SizeInfo{{Description: "44", HasStock: true, Restocked: true}},
+		})
+
+		assert.Eventually(t, func() bool {
+			return recorder.count("1") == 1
+		}, time.Second, 10*time.Millisecond)
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		assert.Len(t, recorder.restocks["1"][0].Sizes, 1, "only the restock of 44 should be sent")
+	})
+
+	t.Run("WithStatus", func(t *testing.T) {
+		watchlist, _, _ := newTestWatchlist(t)
+
+		_, err := watchlist.Watch(testUrl, "test", "1", nil, "someone")
+		assert.NoError(t, err)
+		_, err = watchlist.Watch(testUrl, "test", "2", nil, "someone")
+		assert.NoError(t, err)
+
+		status := watchlist.Status()
+		assert.Equal(t, 2, status.Watches)
+		assert.Equal(t, 1, status.Products)
+		assert.Equal(t, 2, status.Proxies)
+		assert.Equal(t, 1, status.BenchedProxies)
+		assert.Equal(t, uint64(3), status.Suppressed.Unstable)
+	})
+}
+
+func TestWatchlistFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "watchlist.json")
+	recorder := &chatRecorder{restocks: map[string][]nkmonitor.RestockInfo{}}
+	var errs []error
+
+	watchlist := NewWatchlist(newFakeMonitor(), WatchlistOptions{File: file})
+	watchlist.Register("test", recorder.factory)
+	watchlist.Register("other", recorder.factory)
+
+	_, err := watchlist.Watch(testUrl, "test", "1", []string{"42"}, "someone")
+	assert.NoError(t, err)
+	_, err = watchlist.Watch(testUrl, "other", "2", nil, "someone")
+	assert.NoError(t, err)
+	_, err = watchlist.Watch("https://www.nike.com.br/tenis/other.html", "test", "1", nil, "someone")
+	assert.NoError(t, err)
+	_, err = watchlist.Unwatch("https://www.nike.com.br/tenis/other.html", "test", "1")
+	assert.NoError(t, err)
+	watchlist.Close()
+
+	monitor := newFakeMonitor()
+	restored := NewWatchlist(monitor, WatchlistOptions{File: file, OnError: func(watch Watch, err error) {
+		errs = append(errs, err)
+	}})
+	defer restored.Close()
+	restored.Register("test", recorder.factory)
+	assert.NoError(t, restored.Load())
+
+	if watches := restored.All(); assert.Len(t, watches, 1) {
+		assert.Equal(t, "/tenis/test.html", watches[0].Path)
+		assert.Equal(t, []string{"42"}, watches[0].Sizes)
+		assert.NotEmpty(t, watches[0].TaskID)
+	}
+	assert.Len(t, monitor.tasks, 1)
+	if assert.Len(t, errs, 1) {
+		assert.ErrorIs(t, errs[0], ErrUnknownPlatform)
+	}
+
+	_, err = restored.SetSizes(testUrl, "test", "1", nil)
+	assert.NoError(t, err)
+
+	var saved []Watch
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &saved))
+	if assert.Len(t, saved, 2, "watches of platforms not registered should be kept") {
+		assert.Nil(t, saved[0].Sizes)
+		assert.Equal(t, "other", saved[1].Platform)
+	}
+
+	t.Run("WithoutFile", func(t *testing.T) {
+		watchlist := NewWatchlist(newFakeMonitor(), WatchlistOptions{File: filepath.Join(t.TempDir(), "missing.json")})
+		defer watchlist.Close()
+		assert.NoError(t, watchlist.Load())
+		assert.Empty(t, watchlist.All())
+	})
+}
+
+func TestWatchlistWrap(t *testing.T) {
+	monitor := newFakeMonitor()
+	var wrapped []string
+	watchlist := NewWatchlist(monitor, WatchlistOptions{
+		Wrap: func(name string, notifyer notify.Notifyer) notify.Notifyer {
+			wrapped = append(wrapped, name)
+			return notifyer
+		},
+	})
+	defer watchlist.Close()
+	watchlist.Register("test", func(chat string) (notify.Notifyer, error) {
+		if chat == "bad" {
+			return nil, errors.New("bad chat")
+		}
+		return notify.NoopNotifyer{}, nil
+	})
+
+	_, err := watchlist.Watch(testUrl, "test", "1", nil, "someone")
+	assert.NoError(t, err)
+	_, err = watchlist.Watch("https://www.nike.com.br/tenis/other.html", "test", "1", nil, "someone")
+	assert.NoError(t, err)
+	_, err = watchlist.Watch(testUrl, "test", "bad", nil, "someone")
+	assert.EqualError(t, err, "bad chat")
+
+	assert.Equal(t, []string{"test:1"}, wrapped, "chats should share their notifyer")
+}
+
+func TestParseSizes(t *testing.T) {
+	assert.Equal(t, []string{"40", "41", "35,5"}, parseSizes(" 40 41  35,5 "))
+	assert.Nil(t, parseSizes(""))
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", truncate("short", 10))
+	assert.Equal(t, "abcdefg…", truncate("abcdefghijklmnop", 10))
+	assert.Equal(t, "ação…", truncate("açãoçãoção", 10), "multi-byte characters should not be split")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/rodjunger/nkmonitor/cmd/bot"
+	"github.com/rs/zerolog/log"
+)
+
+// discordBotOptions parses the flags of the discord bot
+func discordBotOptions() (bot.DiscordOptions, error) {
+	options := bot.DiscordOptions{Token: cfg.discordBotToken, Template: cfg.botTemplate}
+
+	if cfg.discordBotGuild != "" {
+		guildID, err := snowflake.Parse(cfg.discordBotGuild)
+		if err != nil {
+			return options, fmt.Errorf("invalid discord bot guild: %w", err)
+		}
+		options.GuildID = guildID
+	}
+
+	for _, role := range cfg.discordBotRoles {
+		roleID, err := snowflake.Parse(role)
+		if err != nil {
+			return options, fmt.Errorf("invalid discord bot role %s: %w", role, err)
+		}
+		options.Roles = append(options.Roles, roleID)
+	}
+
+	return options, nil
+}
+
+// botsEnabled reports whether products can be watched from any bot
+func botsEnabled() bool {
+	return cfg.discordBotToken != "" || cfg.telegramBotToken != ""
+}
+
+// startBots creates the watchlist of monitor, restores the saved watches and starts the bots enabled. The returned function stops them
+func startBots(monitor bot.Monitor) (func(), error) {
+	watchlist := bot.NewWatchlist(monitor, bot.WatchlistOptions{
+		Wrap: queueBotNotifyer,
+		File: cfg.watchlistFile,
+		OnError: func(watch bot.Watch, err error) {
+			log.Error().Err(err).Str("platform", watch.Platform).Str("chat", watch.Chat).Str("url", watch.Url).Msg("Watchlist error.")
+		},
+	})
+
+	var stops []func()
+	stop := func() {
+		for _, stop := range stops {
+			stop()
+		}
+		watchlist.Close()
+	}
+
+	// The bots register their platforms when created, they must be before the watches are restored
+	var (
+		discordBot  *bot.DiscordBot
+		telegramBot *bot.TelegramBot
+		err         error
+	)
+
+	if cfg.discordBotToken != "" {
+		if discordBot, err = bot.NewDiscordBot(watchlist, cfg.discordBot); err != nil {
+			stop()
+			return nil, err
+		}
+	}
+
+	if cfg.telegramBotToken != "" {
+		telegramBot, err = bot.NewTelegramBot(watchlist, bot.TelegramOptions{
+			Token:        cfg.telegramBotToken,
+			ApiUrl:       cfg.telegramApi,
+			Template:     cfg.botTemplate,
+			AllowedChats: cfg.telegramBotChats,
+			MaxWatches:   cfg.telegramBotMax,
+			OnError: func(err error) {
+				log.Error().Err(err).Msg("Telegram bot error.")
+			},
+		})
+		if err != nil {
+			stop()
+			return nil, err
+		}
+	}
+
+	if err := watchlist.Load(); err != nil {
+		stop()
+		return nil, fmt.Errorf("loading watchlist: %w", err)
+	}
+	log.Info().Int("watches", len(watchlist.All())).Msg("Watchlist loaded.")
+
+	if discordBot != nil {
+		if err := discordBot.Start(context.Background()); err != nil {
+			stop()
+			return nil, fmt.Errorf("starting discord bot: %w", err)
+		}
+		stops = append(stops, func() { discordBot.Close(context.Background()) })
+		log.Info().Msg("Discord bot started.")
+	}
+
+	if telegramBot != nil {
+		telegramBot.Start()
+		stops = append(stops, telegramBot.Close)
+		log.Info().Msg("Telegram bot started.")
+	}
+
+	return stop, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/rodjunger/nkmonitor/cmd/notify"
+)
+
+// fileConfig is the format of the file given with --config, everything in it is optional and complements the flags
+type fileConfig struct {
+	ProxyGroups map[string][]string `json:"proxyGroups"` // ProxyGroups maps group names to proxies in the same format as --proxies
+	Tasks       []taskConfig        `json:"tasks"`
+	Notifiers   []notifierConfig    `json:"notifiers"`
+}
+
+type taskConfig struct {
+	Url         string          `json:"url"`
+	ProxyGroups []string        `json:"proxyGroups"` // ProxyGroups are the groups the task draws proxies from, in order of preference
+	Rotation    *rotationConfig `json:"rotation"`    // Rotation overrides --rotation for this task
+}
+
+type rotationConfig struct {
+	Kind     string `json:"kind"`
+	Requests int    `json:"requests"`
+	Interval string `json:"interval"` // Interval is in time.ParseDuration format, example: 5m
+}
+
+// notifierConfig is a notifier and the rules of the restocks it gets, the fields used depend on the type
+type notifierConfig struct {
+	Name        string               `json:"name"` // Name identifies the notifier in logs, defaults to the type and position
+	Type        string               `json:"type"` // Type is discord, telegram, slack, webhook, ntfy, gotify, mqtt, redis, nats or kafka
+	Url         string               `json:"url"`  // Url of the webhook, server or broker, comma separated kafka brokers. For telegram it's the Bot API url, optional
+	Token       string               `json:"token"`
+	Chats       []string             `json:"chats"`  // Chats are the telegram chat ids
+	Topic       string               `json:"topic"`  // Topic is the ntfy, kafka or mqtt topic, the nats subject or the redis key
+	Secret      string               `json:"secret"` // Secret signs the webhook requests
+	Headers     map[string]string    `json:"headers"`
+	QuietPeriod string               `json:"quietPeriod"` // QuietPeriod makes discord notifiers edit the message of a product until it goes this long without restocking, example: 30m
+	Template    string               `json:"template"`    // Template is a template file overriding --template for this notifier
+	Mentions    []notify.MentionRule `json:"mentions"`    // Mentions are the roles and users discord notifiers ping for the restocks matching their rules
+	Digest      *digestConfig        `json:"digest"`      // Digest makes the notifier send summaries of the restocks instead of a message for each one
+	Rules       []notify.Rule        `json:"rules"`       // Rules are alternatives, the notifier gets the restocks matching any of them, or every restock if there are none
+}
+
+type digestConfig struct {
+	Interval string `json:"interval"` // Interval between digests in time.ParseDuration format, defaults to 1h
+	MaxItems int    `json:"maxItems"` // MaxItems sends the digest early once it has this many restocks
+}
+
+func loadConfigFile(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed fileConfig
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return &parsed, nil
+}
+
+func (r rotationConfig) strategy() (nkmonitor.RotationStrategy, error) {
+	kind, err := nkmonitor.ParseRotationKind(r.Kind)
+	if err != nil {
+		return nkmonitor.RotationStrategy{}, err
+	}
+
+	var interval time.Duration
+	if r.Interval != "" {
+		if interval, err = time.ParseDuration(r.Interval); err != nil {
+			return nkmonitor.RotationStrategy{}, err
+		}
+	}
+
+	return nkmonitor.RotationStrategy{Kind: kind, Requests: r.Requests, Interval: interval}, nil
+}
+
+// options converts the task config to the options passed to AddTask
+func (t taskConfig) options() ([]nkmonitor.TaskOption, error) {
+	var opts []nkmonitor.TaskOption
+
+	if len(t.ProxyGroups) > 0 {
+		opts = append(opts, nkmonitor.WithTaskProxyGroups(t.ProxyGroups...))
+	}
+
+	if t.Rotation != nil {
+		strategy, err := t.Rotation.strategy()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, nkmonitor.WithTaskRotation(strategy))
+	}
+
+	return opts, nil
+}
+
+// notifyer creates the notifier described by the config
+func (n notifierConfig) notifyer() (notify.Notifyer, error) {
+	if len(n.Mentions) > 0 && (n.Type != "discord" || n.QuietPeriod != "") {
+		return nil, errors.New("mentions are only supported by discord notifiers without a quiet period")
+	}
+
+	switch n.Type {
+	case "discord":
+		if n.QuietPeriod != "" {
+			quietPeriod, err := time.ParseDuration(n.QuietPeriod)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quiet period: %w", err)
+			}
+			return notify.NewDiscordLiveNotifyer(n.Url, quietPeriod)
+		}
+		notifyer, err := notify.NewDiscordNotifyer(n.Url)
+		if err != nil {
+			return nil, err
+		}
+		notifyer.SetMentions(n.Mentions)
+		return notifyer, nil
+	case "telegram":
+		return notify.NewTelegramNotifyer(n.Token, n.Chats, n.Url)
+	case "slack":
+		return notify.NewSlackNotifyer(n.Url)
+	case "webhook":
+		return notify.NewWebhookNotifyer(n.Url, notify.WebhookOptions{Headers: n.Headers, Secret: n.Secret})
+	case "ntfy":
+		return notify.NewNtfyNotifyer(n.Url, n.Topic, n.Token)
+	case "gotify":
+		return notify.NewGotifyNotifyer(n.Url, n.Token)
+	case "mqtt":
+		return notify.NewMQTTNotifyer(notify.MQTTOptions{Broker: n.Url, Topic: n.Topic, QoS: 1})
+	case "redis":
+		return notify.NewRedisNotifyer(notify.RedisOptions{Url: n.Url, Key: n.Topic})
+	case "nats":
+		return notify.NewNATSNotifyer(notify.NATSOptions{Url: n.Url, Subject: n.Topic, Token: n.Token})
+	case "kafka":
+		return notify.NewKafkaNotifyer(notify.KafkaOptions{Brokers: strings.Split(n.Url, ","), Topic: n.Topic})
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", n.Type)
+	}
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+)
+
+// DeadLetter is a restock that couldn't be sent, stored as a line of the dead letter file
+type DeadLetter struct {
+	Notifier string                `json:"notifier"` // Notifier is the name of the route the restock was meant for
+	FailedAt time.Time             `json:"failedAt"`
+	Attempts int                   `json:"attempts"`
+	Error    string                `json:"error"`
+	Restock  nkmonitor.RestockInfo `json:"restock"`
+}
+
+// DeadLetterFile appends dead letters to a JSON lines file, the file is only created when the first one is written
+type DeadLetterFile struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewDeadLetterFile returns a DeadLetterFile that writes to path
+func NewDeadLetterFile(path string) *DeadLetterFile {
+	return &DeadLetterFile{path: path}
+}
+
+// Write appends letter to the file
+func (d *DeadLetterFile) Write(letter DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	file, err := os.OpenFile(d.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// ReadDeadLetters reads every dead letter of a file written by DeadLetterFile
+func ReadDeadLetters(path string) ([]DeadLetter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var letters []DeadLetter
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var letter DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, scanner.Err()
+}
+
+// WriteDeadLetters replaces the content of the file at path with letters, removing it if there are none
+func WriteDeadLetters(path string, letters []DeadLetter) error {
+	if len(letters) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	temp := path + ".tmp"
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, letter := range letters {
+		if err := encoder.Encode(letter); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	// Renaming keeps the original file intact if writing fails halfway
+	return os.Rename(temp, path)
+}
@@ -0,0 +1,302 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rodjunger/nkmonitor"
+)
+
+// KindDigest is the kind of the summaries sent by DigestNotifyer, templates can give it a color
+const KindDigest = "digest"
+
+const (
+	// DefaultDigestInterval is how often digests are sent when DigestOptions.Interval is not set
+	DefaultDigestInterval = time.Hour
+	// digestMaxProducts is how many products are listed in a digest, Discord doesn't take more fields in an embed
+	digestMaxProducts = 25
+	// digestMaxLength is the length the listed products can take, leaving room for the title and description in the 6000 characters of a Discord message
+	digestMaxLength = 5000
+)
+
+type DigestOptions struct {
+	Interval time.Duration // Interval between digests, DefaultDigestInterval if not set
+	MaxItems int           // MaxItems sends the digest early once it has this many restocks, 0 only sends it on the interval
+	// OnError is called when a digest can't be sent, its restocks are kept for the next one unless err is a PermanentError
+	OnError func(err error)
+}
+
+// digestEntry has the restocks of a product since the last digest
+type digestEntry struct {
+	info       nkmonitor.RestockInfo // info is the latest restock
+	firstPrice string
+	restocks   int
+	restocked  []string // restocked are the sizes that restocked, in the order they did
+}
+
+// merge adds the restocks of older, a product from a digest that failed to be sent
+func (e *digestEntry) merge(older *digestEntry) {
+	e.firstPrice = older.firstPrice
+	e.restocks += older.restocks
+	e.restocked = appendMissing(older.restocked, e.restocked)
+}
+
+// appendMissing appends the values of more that are not in values yet
+func appendMissing(values, more []string) []string {
+	for _, value := range more {
+		found := false
+		for _, existing := range values {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// DigestNotifyer collects restocks and sends a single summary of them on an interval, or once it has DigestOptions.MaxItems restocks
+type DigestNotifyer struct {
+	notifyer MessageNotifyer
+	options  DigestOptions
+	template *Template
+	now      func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]*digestEntry // entries by product path
+	order    []string                // order has the paths in the order they first restocked
+	restocks int
+	since    time.Time
+
+	full      chan struct{}
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewDigestNotifyer sends digests of the restocks it gets to notifyer until closed
+func NewDigestNotifyer(notifyer MessageNotifyer, options DigestOptions) (*DigestNotifyer, error) {
+	if notifyer == nil {
+		return nil, errors.New("nil notifyer")
+	}
+	if options.Interval < 0 || options.MaxItems < 0 {
+		return nil, errors.New("digest interval and max items can't be negative")
+	}
+	if options.Interval == 0 {
+		options.Interval = DefaultDigestInterval
+	}
+
+	d := newDigestNotifyer(notifyer, options)
+	go d.run()
+
+	return d, nil
+}
+
+func newDigestNotifyer(notifyer MessageNotifyer, options DigestOptions) *DigestNotifyer {
+	return &DigestNotifyer{
+		notifyer: notifyer,
+		options:  options,
+		now:      time.Now,
+		entries:  map[string]*digestEntry{},
+		full:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// SetTemplate changes the template of the digests and of the notifyer
+func (d *DigestNotifyer) SetTemplate(t *Template) {
+	d.template = t
+	if templater, ok := d.notifyer.(Templater); ok {
+		templater.SetTemplate(t)
+	}
+}
+
+// Unwrap returns the notifyer the digests are sent to
+func (d *DigestNotifyer) Unwrap() MessageNotifyer {
+	return d.notifyer
+}
+
+// Notify adds info to the next digest
+func (d *DigestNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	if d == nil {
+		return errors.New("nil instance")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.entries) == 0 {
+		d.since = d.now()
+	}
+
+	entry, ok := d.entries[info.Path]
+	if !ok {
+		entry = &digestEntry{firstPrice: info.Price}
+		d.entries[info.Path] = entry
+		d.order = append(d.order, info.Path)
+	}
+	entry.info = info
+	entry.restocks++
+	for _, size := range info.Sizes {
+		if size != nil && size.Restocked {
+			entry.restocked = appendMissing(entry.restocked, []string{size.Description})
+		}
+	}
+
+	d.restocks++
+	if d.options.MaxItems > 0 && d.restocks >= d.options.MaxItems {
+		select {
+		case d.full <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Close sends the restocks collected so far, stops sending digests and closes the notifyer if it has a Close method
+func (d *DigestNotifyer) Close() {
+	d.closeOnce.Do(func() {
+		close(d.done)
+		<-d.stopped
+		if closer, ok := d.notifyer.(interface{ Close() }); ok {
+			closer.Close()
+		}
+	})
+	<-d.stopped
+}
+
+func (d *DigestNotifyer) run() {
+	defer close(d.stopped)
+
+	ticker := time.NewTicker(d.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.flush()
+		case <-d.full:
+			d.flush()
+			ticker.Reset(d.options.Interval)
+		case <-d.done:
+			d.flush()
+			return
+		}
+	}
+}
+
+// flush sends the collected restocks as a digest, putting them back if it fails with an error that can be retried
+func (d *DigestNotifyer) flush() {
+	d.mu.Lock()
+	entries, order, since := d.entries, d.order, d.since
+	d.entries, d.order, d.restocks = map[string]*digestEntry{}, nil, 0
+	d.mu.Unlock()
+
+	if len(order) == 0 {
+		return
+	}
+
+	message := digestMessage(d.template, entries, order, since, d.now())
+	err := d.notifyer.NotifyMessage(message)
+	if err == nil {
+		return
+	}
+
+	if d.options.OnError != nil {
+		d.options.OnError(err)
+	}
+
+	// Sending the same digest again would fail again, the restocks are dropped instead of blocking the next digests forever
+	var permanent *PermanentError
+	if errors.As(err, &permanent) {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, path := range order {
+		if newer, ok := d.entries[path]; ok {
+			newer.merge(entries[path])
+		} else {
+			d.entries[path] = entries[path]
+		}
+		d.restocks += entries[path].restocks
+	}
+	// The products of the failed digest go first
+	d.order = appendMissing(order, d.order)
+	d.since = since
+}
+
+// digestMessage summarizes the restocks of entries between since and until, listing the products in order
+func digestMessage(t *Template, entries map[string]*digestEntry, order []string, since, until time.Time) Message {
+	if t == nil {
+		t = defaultTemplate
+	}
+
+	restocks := 0
+	for _, entry := range entries {
+		restocks += entry.restocks
+	}
+
+	color, ok := t.colors[KindDigest]
+	if !ok {
+		color = t.colors[KindRestock]
+	}
+
+	message := Message{
+		Title:       t.translate("Restock digest"),
+		Description: fmt.Sprintf(t.translate("%d restocks of %d products from %s to %s"), restocks, len(order), since.Format("15:04"), until.Format("15:04")),
+		Color:       color,
+		LinkText:    t.translate("Open product"),
+	}
+
+	length := 0
+	for _, path := range order {
+		entry := entries[path]
+
+		name := entry.info.Name
+		if entry.info.Code != "" {
+			name += " (" + entry.info.Code + ")"
+		}
+
+		price := entry.info.Price
+		if entry.firstPrice != "" && entry.firstPrice != entry.info.Price {
+			price = entry.firstPrice + " → " + entry.info.Price
+		}
+
+		lines := []string{fmt.Sprintf("%s: %s", t.translate("Price"), price)}
+		if len(entry.restocked) > 0 {
+			lines = append(lines, fmt.Sprintf("%s: %s", t.translate("Restocked sizes"), strings.Join(entry.restocked, ", ")))
+		}
+		lines = append(lines, productUrl(entry.info))
+
+		field := MessageField{Name: name, Value: strings.Join(lines, "\n")}
+		length += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+		if len(message.Fields) == digestMaxProducts || length > digestMaxLength {
+			break
+		}
+		message.Fields = append(message.Fields, field)
+
+		for _, size := range entry.info.Sizes {
+			if size != nil && size.IsAvailable {
+				message.Available = true
+			}
+		}
+	}
+
+	if len(message.Fields) < len(order) {
+		message.Description += "\n" + fmt.Sprintf(t.translate("%d more products not listed"), len(order)-len(message.Fields))
+	}
+
+	return message
+}
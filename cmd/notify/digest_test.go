@@ -0,0 +1,175 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/stretchr/testify/assert"
+)
+
+// digestRestock is a restock of path where the sizes given restocked
+func digestRestock(path, price string, restocked ...string) nkmonitor.RestockInfo {
+	info := nkmonitor.RestockInfo{Path: path, Name: "Shoe " + path, Code: "ABC123", Price: price}
+	for _, size := range restocked {
+		info.Sizes = append(info.Sizes, &nkmonitor.SizeInfo{Description: size, HasStock: true, Restocked: true})
+	}
+	return info
+}
+
+func newTestDigest(recorder *recordingNotifyer, options DigestOptions) *DigestNotifyer {
+	digest := newDigestNotifyer(recorder, options)
+	start := time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC)
+	digest.now = func() time.Time {
+		start = start.Add(30 * time.Minute)
+		return start
+	}
+	return digest
+}
+
+func TestNewDigestNotifyer(t *testing.T) {
+	_, err := NewDigestNotifyer(nil, DigestOptions{})
+	assert.EqualError(t, err, "nil notifyer")
+
+	_, err = NewDigestNotifyer(&recordingNotifyer{}, DigestOptions{MaxItems: -1})
+	assert.Error(t, err)
+
+	inner := &recordingNotifyer{}
+	digest, err := NewDigestNotifyer(inner, DigestOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultDigestInterval, digest.options.Interval)
+	assert.Same(t, inner, digest.Unwrap())
+	digest.Close()
+}
+
+func TestDigestNotifyer(t *testing.T) {
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var digest *DigestNotifyer
+		assert.EqualError(t, digest.Notify(testRestock), "nil instance")
+	})
+
+	t.Run("WithRestocks", func(t *testing.T) {
+		recorder := &recordingNotifyer{}
+		digest := newTestDigest(recorder, DigestOptions{})
+
+		assert.NoError(t, digest.Notify(digestRestock("/a", "R$ 100,00", "40")))
+		assert.NoError(t, digest.Notify(digestRestock("/b", "R$ 50,00", "38")))
+		assert.NoError(t, digest.Notify(digestRestock("/a", "R$ 90,00", "41", "40")))
+		digest.flush()
+
+		assert.Len(t, recorder.messages, 1)
+		message := recorder.messages[0]
+		assert.Equal(t, "Restock digest", message.Title)
+		assert.Equal(t, "3 restocks of 2 products from 12:30 to 13:00", message.Description)
+		assert.Equal(t, []MessageField{
+			{Name: "Shoe /a (ABC123)", Value: "Price: R$ 100,00 → R$ 90,00\nRestocked sizes: 40, 41\nhttps://www.nike.com.br/a"},
+			{Name: "Shoe /b (ABC123)", Value: "Price: R$ 50,00\nRestocked sizes: 38\nhttps://www.nike.com.br/b"},
+		}, message.Fields)
+
+		digest.flush()
+		assert.Len(t, recorder.messages, 1, "empty digests should not be sent")
+	})
+
+	t.Run("WithFailure", func(t *testing.T) {
+		var failures int
+		recorder := &recordingNotifyer{err: errors.New("down")}
+		digest := newTestDigest(recorder, DigestOptions{OnError: func(err error) { failures++ }})
+
+		assert.NoError(t, digest.Notify(digestRestock("/a", "R$ 100,00", "40")))
+		digest.flush()
+		assert.Equal(t, 1, failures)
+
+		recorder.err = nil
+		assert.NoError(t, digest.Notify(digestRestock("/a", "R$ 90,00", "41")))
+		assert.NoError(t, digest.Notify(digestRestock("/b", "R$ 50,00", "38")))
+		digest.flush()
+
+		assert.Len(t, recorder.messages, 2)
+		message := recorder.messages[1]
+		assert.Equal(t, "3 restocks of 2 products from 12:30 to 13:30", message.Description, "restocks of the failed digest should be kept")
+		assert.Equal(t, "Price: R$ 100,00 → R$ 90,00\nRestocked sizes: 40, 41\nhttps://www.nike.com.br/a", message.Fields[0].Value)
+	})
+
+	t.Run("WithPermanentFailure", func(t *testing.T) {
+		var failures int
+		recorder := &recordingNotifyer{err: &PermanentError{Err: errors.New("invalid form body")}}
+		digest := newTestDigest(recorder, DigestOptions{OnError: func(err error) { failures++ }})
+
+		assert.NoError(t, digest.Notify(digestRestock("/a", "R$ 100,00", "40")))
+		digest.flush()
+		assert.Equal(t, 1, failures)
+
+		recorder.err = nil
+		assert.NoError(t, digest.Notify(digestRestock("/b", "R$ 50,00", "38")))
+		digest.flush()
+
+		assert.Len(t, recorder.messages, 2)
+		assert.Contains(t, recorder.messages[1].Description, "1 restocks of 1 products", "restocks of a digest that can't be sent should be dropped")
+	})
+
+	t.Run("WithMaxItems", func(t *testing.T) {
+		recorder := &recordingNotifyer{}
+		digest, err := NewDigestNotifyer(recorder, DigestOptions{Interval: time.Hour, MaxItems: 2})
+		assert.NoError(t, err)
+		defer digest.Close()
+
+		assert.NoError(t, digest.Notify(digestRestock("/a", "R$ 100,00", "40")))
+		assert.NoError(t, digest.Notify(digestRestock("/a", "R$ 100,00", "41")))
+
+		assert.Eventually(t, func() bool {
+			recorder.mu.Lock()
+			defer recorder.mu.Unlock()
+			return len(recorder.messages) == 1
+		}, time.Second, 10*time.Millisecond, "the digest should be sent once it has MaxItems restocks")
+	})
+
+	t.Run("WithClose", func(t *testing.T) {
+		recorder := &recordingNotifyer{}
+		digest, err := NewDigestNotifyer(recorder, DigestOptions{Interval: time.Hour})
+		assert.NoError(t, err)
+
+		assert.NoError(t, digest.Notify(digestRestock("/a", "R$ 100,00", "40")))
+		digest.Close()
+		digest.Close()
+
+		assert.Len(t, recorder.messages, 1, "closing should send the pending restocks")
+		assert.Equal(t, 1, recorder.closed, "the notifyer should be closed once")
+	})
+
+	t.Run("WithManyProducts", func(t *testing.T) {
+		entries := map[string]*digestEntry{}
+		var order []string
+		for i := 0; i < 30; i++ {
+			path := "/" + string(rune('a'+i))
+			entries[path] = &digestEntry{info: digestRestock(path, "R$ 1,00"), restocks: 1}
+			order = append(order, path)
+		}
+
+		message := digestMessage(nil, entries, order, time.Time{}, time.Time{})
+		assert.Len(t, message.Fields, digestMaxProducts)
+		assert.Contains(t, message.Description, "5 more products not listed")
+	})
+
+	t.Run("WithLongProducts", func(t *testing.T) {
+		entries := map[string]*digestEntry{}
+		var order []string
+		for i := 0; i < 20; i++ {
+			path := "/" + strings.Repeat(string(rune('a'+i)), 250)
+			entries[path] = &digestEntry{info: digestRestock(path, "R$ 1,00", "38", "39", "40"), restocks: 1}
+			order = append(order, path)
+		}
+
+		message := digestMessage(nil, entries, order, time.Time{}, time.Time{})
+		length := 0
+		for _, field := range message.Fields {
+			length += len(field.Name) + len(field.Value)
+		}
+		assert.LessOrEqual(t, length, digestMaxLength, "the digest should fit in a Discord message")
+		assert.Less(t, len(message.Fields), 20)
+		assert.Contains(t, message.Description, fmt.Sprintf("%d more products not listed", 20-len(message.Fields)))
+		assert.Less(t, embedLength(discordEmbed(message)), discordMessageLimit)
+	})
+}
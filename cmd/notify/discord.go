@@ -1,55 +1,62 @@
 package notify
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/disgoorg/disgo/discord"
-	"github.com/disgoorg/disgo/webhook"
+	"github.com/disgoorg/disgo/rest"
 	"github.com/disgoorg/snowflake/v2"
 	"github.com/rodjunger/nkmonitor"
 )
 
 const (
 	checkMark = "✓"
+	// discordFieldLimit is the maximum length of an embed field value
+	discordFieldLimit = 1024
 )
 
+// DiscordNotifyer sends restocks as embeds, restocks sent to the same webhook within a short window are coalesced into one message
 type DiscordNotifyer struct {
-	w webhook.Client
+	batcher  *discordBatcher
+	template *Template
+	mentions []MentionRule
 }
 
 func NewDiscordNotifyer(webhookUrl string) (*DiscordNotifyer, error) {
-	client, err := webhookClientFromUrl(webhookUrl)
+	id, token, err := parseWebhookUrl(webhookUrl)
 	if err != nil {
 		return nil, err
 	}
-	return &DiscordNotifyer{client}, nil
+	return &DiscordNotifyer{batcher: batcherForWebhook(id, token)}, nil
 }
 
-func webhookClientFromUrl(webhookUrl string) (webhook.Client, error) {
+func parseWebhookUrl(webhookUrl string) (snowflake.ID, string, error) {
 	if webhookUrl == "" {
-		return nil, errors.New("empty webhook")
+		return 0, "", errors.New("empty webhook")
 	}
 
 	urlParts := strings.Split(webhookUrl, "/")
 
 	//This feels very wrong
 	if len(urlParts) != 7 {
-		return nil, errors.New("invalid webhook")
+		return 0, "", errors.New("invalid webhook")
 	}
 
 	snowFlake, err := snowflake.Parse(urlParts[5])
 
 	if err != nil {
-		return nil, err
+		return 0, "", err
 	}
 
 	token := urlParts[6]
 
-	client := webhook.New(snowFlake, token)
-
-	return client, nil
+	return snowFlake, token, nil
 }
 
 func sizeToString(size *nkmonitor.SizeInfo) string {
@@ -65,40 +72,105 @@ func sizeToString(size *nkmonitor.SizeInfo) string {
 	return fmt.Sprintf("%s - %s - %s", size.Description, size.Sku, symbol)
 }
 
+// SetTemplate changes the template of the embeds
+func (d *DiscordNotifyer) SetTemplate(t *Template) {
+	d.template = t
+}
+
+// SetMentions sets the rules of the roles and users pinged by restocks
+func (d *DiscordNotifyer) SetMentions(rules []MentionRule) {
+	d.mentions = rules
+}
+
+// discordEmbed lays out a message as an embed
+func discordEmbed(message Message) discord.Embed {
+	embed := discord.NewEmbedBuilder().SetTitle(message.Title).
+		SetDescription(message.Description).
+		SetColor(message.Color).
+		SetThumbnail(message.Picture).
+		SetURL(message.Url)
+
+	// Discord rejects footers without text
+	if message.Footer != "" {
+		embed.SetFooterText(message.Footer)
+	}
+
+	for _, field := range message.Fields {
+		embed.AddField(field.Name, field.Value, field.Inline)
+	}
+
+	if len(message.Links) > 0 {
+		embed.AddField(message.LinksTitle, linkLines(message.Links, func(text string) string { return "**" + text + "**" }, func(link Link) string {
+			return fmt.Sprintf("[%s](%s)", link.Name, link.Url)
+		}, discordFieldLimit), false)
+	}
+
+	return embed.Build()
+}
+
 func (d *DiscordNotifyer) Notify(info nkmonitor.RestockInfo) error {
 	if d == nil {
 		return errors.New("nil instance")
 	}
 
-	var availableSizes, inStockSizes []string
+	rendered, err := renderMessage(d.template, info)
+	if err != nil {
+		return err
+	}
 
-	for i := range info.Sizes { //Using i to avoid problems with reused loop variable and pointers
-		infoStr := sizeToString(info.Sizes[i])
-		if info.Sizes[i].IsAvailable {
-			availableSizes = append(availableSizes, infoStr)
-		} else {
-			inStockSizes = append(inStockSizes, infoStr)
-		}
+	return d.batcher.add(discordEmbed(rendered), mentionsFor(d.mentions, info))
+}
+
+// NotifyNow sends info in a message of its own right away instead of waiting for the restocks sent with it,
+// so the time it takes is only Discord's
+func (d *DiscordNotifyer) NotifyNow(info nkmonitor.RestockInfo) error {
+	if d == nil {
+		return errors.New("nil instance")
 	}
 
-	webHook := discord.NewEmbedBuilder().SetTitle(info.Name+" just restocked!").
-		SetColor(65280).
-		SetFooterText("Powered by the openMonitors project").
-		SetThumbnail(info.Picture).
-		SetURL("https://www.nike.com.br"+info.Path).
-		AddField("Price", info.Price, true).
-		AddField("Code", info.Code, true)
-	if len(availableSizes) > 0 {
-		webHook.AddField("Available sizes (size - SKU - restocked)", strings.Join(availableSizes, "\n"), false)
+	rendered, err := renderMessage(d.template, info)
+	if err != nil {
+		return err
 	}
-	if len(inStockSizes) > 0 {
-		webHook.AddField("In stock sizes (size - SKU - restocked)", strings.Join(inStockSizes, "\n"), false)
+
+	return d.batcher.sendNow(discordEmbed(rendered), mentionsFor(d.mentions, info))
+}
+
+// NotifyMessage sends message as an embed, without mentions
+func (d *DiscordNotifyer) NotifyMessage(message Message) error {
+	if d == nil {
+		return errors.New("nil instance")
 	}
 
-	if _, err := d.w.CreateEmbeds([]discord.Embed{webHook.Build()}); err != nil {
-		fmt.Println(err)
+	return d.batcher.add(discordEmbed(message), discordMentions{})
+}
+
+// discordError marks rate limits and client errors so the queue knows if and when to retry them.
+// Request errors have the webhook url, token included, it's left out
+func discordError(err error) error {
+	err = StripUrl(err)
+
+	var restErr *rest.Error
+	if !errors.As(err, &restErr) || restErr.Response == nil {
 		return err
 	}
 
-	return nil
+	status := restErr.Response.StatusCode
+	if status == http.StatusTooManyRequests {
+		// retry_after in the body has milliseconds, the header is rounded up to seconds
+		var body struct {
+			RetryAfter float64 `json:"retry_after"`
+		}
+		if json.Unmarshal(restErr.RsBody, &body) == nil && body.RetryAfter > 0 {
+			return &RetryAfterError{Err: err, After: time.Duration(body.RetryAfter * float64(time.Second))}
+		}
+		seconds, _ := strconv.Atoi(restErr.Response.Header.Get("Retry-After"))
+		return &RetryAfterError{Err: err, After: time.Duration(seconds) * time.Second}
+	}
+
+	if status >= 400 && status < 500 {
+		return &PermanentError{Err: err}
+	}
+
+	return err
 }
@@ -0,0 +1,211 @@
+package notify
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/disgo/webhook"
+	"github.com/disgoorg/snowflake/v2"
+)
+
+const (
+	// DiscordBatchSize is the maximum number of embeds in a webhook message
+	DiscordBatchSize   = 10
+	discordBatchWindow = time.Second
+	// discordMessageLimit is the maximum length of all the embeds of a message together, see embedLength
+	discordMessageLimit = 6000
+)
+
+var (
+	// discordRest is shared by every webhook so its rate limiter sees the buckets and global limits of all of them
+	discordRest     rest.Client
+	discordRestOnce sync.Once
+
+	// discordBatchers has the batcher of every webhook, notifyers for the same webhook share it
+	discordBatchers   = map[snowflake.ID]*discordBatcher{}
+	discordBatchersMu sync.Mutex
+)
+
+// sharedDiscordRest returns the rest client used by every webhook, its rate limiter waits on the bucket headers of each one
+func sharedDiscordRest() rest.Client {
+	discordRestOnce.Do(func() {
+		discordRest = rest.NewClient("")
+	})
+	return discordRest
+}
+
+// batcherForWebhook returns the batcher of a webhook, creating it on the first use
+func batcherForWebhook(id snowflake.ID, token string) *discordBatcher {
+	discordBatchersMu.Lock()
+	defer discordBatchersMu.Unlock()
+
+	if batcher, ok := discordBatchers[id]; ok {
+		return batcher
+	}
+
+	client := webhook.New(id, token, webhook.WithRestClient(sharedDiscordRest()))
+	batcher := newDiscordBatcher(func(embeds []discord.Embed, mentions discordMentions) error {
+		_, err := client.CreateMessage(discord.WebhookMessageCreate{
+			Content:         mentions.content(),
+			Embeds:          embeds,
+			AllowedMentions: mentions.allowed(),
+		})
+		return err
+	}, discordBatchWindow)
+	discordBatchers[id] = batcher
+
+	return batcher
+}
+
+type pendingEmbed struct {
+	embed    discord.Embed
+	mentions discordMentions
+	result   chan error
+}
+
+// embedLength returns the length of embed as Discord counts it for discordMessageLimit
+func embedLength(embed discord.Embed) int {
+	length := utf8.RuneCountInString(embed.Title) + utf8.RuneCountInString(embed.Description)
+	for _, field := range embed.Fields {
+		length += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+	}
+	if embed.Footer != nil {
+		length += utf8.RuneCountInString(embed.Footer.Text)
+	}
+	if embed.Author != nil {
+		length += utf8.RuneCountInString(embed.Author.Name)
+	}
+	return length
+}
+
+// discordBatcher coalesces the embeds added within a window into messages of up to DiscordBatchSize embeds and discordMessageLimit characters
+type discordBatcher struct {
+	send    func(embeds []discord.Embed, mentions discordMentions) error
+	window  time.Duration
+	mu      sync.Mutex
+	pending []pendingEmbed
+	timer   *time.Timer
+	sendMu  sync.Mutex // sendMu keeps batches in order
+}
+
+func newDiscordBatcher(send func(embeds []discord.Embed, mentions discordMentions) error, window time.Duration) *discordBatcher {
+	return &discordBatcher{send: send, window: window}
+}
+
+// add queues embed for the next message, which pings mentions, and waits for it to be sent
+func (b *discordBatcher) add(embed discord.Embed, mentions discordMentions) error {
+	result := make(chan error, 1)
+
+	b.mu.Lock()
+	b.pending = append(b.pending, pendingEmbed{embed: embed, mentions: mentions, result: result})
+	if len(b.pending) >= DiscordBatchSize || b.pendingLength() > discordMessageLimit {
+		batch := b.take()
+		b.mu.Unlock()
+		b.flush(batch)
+	} else {
+		b.schedule()
+		b.mu.Unlock()
+	}
+
+	return <-result
+}
+
+// sendNow sends embed in a message of its own right away, after the batches being sent
+func (b *discordBatcher) sendNow(embed discord.Embed, mentions discordMentions) error {
+	b.sendMu.Lock()
+	defer b.sendMu.Unlock()
+	return discordError(b.send([]discord.Embed{embed}, mentions))
+}
+
+// pendingLength returns the length of the pending embeds, must be called with mu locked
+func (b *discordBatcher) pendingLength() int {
+	length := 0
+	for _, pending := range b.pending {
+		length += embedLength(pending.embed)
+	}
+	return length
+}
+
+// take removes the pending embeds that fit in a message, at least one, must be called with mu locked
+func (b *discordBatcher) take() []pendingEmbed {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	n, length := 0, 0
+	for n < len(b.pending) && n < DiscordBatchSize {
+		length += embedLength(b.pending[n].embed)
+		if n > 0 && length > discordMessageLimit {
+			break
+		}
+		n++
+	}
+	batch := b.pending[:n:n]
+	b.pending = b.pending[n:]
+
+	// Whatever didn't fit goes in the next window
+	if len(b.pending) > 0 {
+		b.schedule()
+	}
+
+	return batch
+}
+
+// schedule sends the pending embeds when the window ends, must be called with mu locked
+func (b *discordBatcher) schedule() {
+	if b.timer != nil {
+		return
+	}
+
+	b.timer = time.AfterFunc(b.window, func() {
+		b.mu.Lock()
+		batch := b.take()
+		b.mu.Unlock()
+		b.flush(batch)
+	})
+}
+
+// flush sends a batch as a single message pinging the mentions of every embed, every embed in it gets the same result.
+// When Discord rejects the message, the embeds are sent apart so only the invalid ones fail
+func (b *discordBatcher) flush(batch []pendingEmbed) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var (
+		embeds   = make([]discord.Embed, len(batch))
+		mentions discordMentions
+	)
+	for i, pending := range batch {
+		embeds[i] = pending.embed
+		mentions = mentions.merge(pending.mentions)
+	}
+
+	b.sendMu.Lock()
+	defer b.sendMu.Unlock()
+
+	err := b.send(embeds, mentions)
+	if len(batch) > 1 && badRequest(err) {
+		for _, pending := range batch {
+			pending.result <- discordError(b.send([]discord.Embed{pending.embed}, pending.mentions))
+		}
+		return
+	}
+
+	err = discordError(err)
+	for _, pending := range batch {
+		pending.result <- err
+	}
+}
+
+// badRequest reports whether Discord rejected a message as invalid
+func badRequest(err error) bool {
+	var restErr *rest.Error
+	return errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusBadRequest
+}
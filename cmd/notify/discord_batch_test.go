@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDiscordSend records the size and the mentions of every message sent, rejecting messages with an embed titled reject
+type fakeDiscordSend struct {
+	mu       sync.Mutex
+	messages []int
+	mentions []discordMentions
+	err      error
+	reject   string
+}
+
+func (f *fakeDiscordSend) send(embeds []discord.Embed, mentions discordMentions) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, len(embeds))
+	f.mentions = append(f.mentions, mentions)
+	for _, embed := range embeds {
+		if f.reject != "" && embed.Title == f.reject {
+			response := &http.Response{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"}
+			return rest.NewError(nil, nil, response, []byte(`{"message": "Invalid Form Body", "code": 50035}`))
+		}
+	}
+	return f.err
+}
+
+// addConcurrently adds n embeds at the same time and returns their results
+func addConcurrently(batcher *discordBatcher, n int) []error {
+	var wg sync.WaitGroup
+	results := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = batcher.add(discord.Embed{Title: strconv.Itoa(i)}, discordMentions{})
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+func TestDiscordBatcher(t *testing.T) {
+	t.Run("WithBurst", func(t *testing.T) {
+		fake := &fakeDiscordSend{}
+		batcher := newDiscordBatcher(fake.send, 50*time.Millisecond)
+
+		for _, err := range addConcurrently(batcher, 23) {
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []int{10, 10, 3}, fake.messages, "embeds should be coalesced in messages of up to 10")
+	})
+
+	t.Run("WithSingleEmbed", func(t *testing.T) {
+		fake := &fakeDiscordSend{}
+		batcher := newDiscordBatcher(fake.send, 10*time.Millisecond)
+
+		start := time.Now()
+		assert.NoError(t, batcher.add(discord.Embed{}, discordMentions{}))
+		assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond, "a lone embed should wait for the window")
+		assert.Equal(t, []int{1}, fake.messages)
+	})
+
+	t.Run("WithSendNow", func(t *testing.T) {
+		fake := &fakeDiscordSend{reject: "reject"}
+		batcher := newDiscordBatcher(fake.send, time.Hour)
+
+		assert.NoError(t, batcher.sendNow(discord.Embed{}, discordMentions{}), "the embed should not wait for the window")
+		assert.ErrorAs(t, batcher.sendNow(discord.Embed{Title: "reject"}, discordMentions{}), new(*PermanentError))
+		assert.Equal(t, []int{1, 1}, fake.messages)
+	})
+
+	t.Run("WithMentions", func(t *testing.T) {
+		fake := &fakeDiscordSend{}
+		batcher := newDiscordBatcher(fake.send, 50*time.Millisecond)
+
+		var wg sync.WaitGroup
+		for _, mentions := range []discordMentions{{roles: []snowflake.ID{1}}, {roles: []snowflake.ID{1, 2}, users: []snowflake.ID{3}}, {}} {
+			wg.Add(1)
+			go func(mentions discordMentions) {
+				defer wg.Done()
+				assert.NoError(t, batcher.add(discord.Embed{}, mentions))
+			}(mentions)
+		}
+		wg.Wait()
+
+		if assert.Len(t, fake.mentions, 1) {
+			assert.Equal(t, "<@&1> <@&2> <@3>", fake.mentions[0].content(), "a message should ping the mentions of all its embeds once")
+		}
+	})
+
+	t.Run("WithLongEmbeds", func(t *testing.T) {
+		fake := &fakeDiscordSend{}
+		batcher := newDiscordBatcher(fake.send, 50*time.Millisecond)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				embed := discord.Embed{Description: strings.Repeat("a", 2000), Fields: []discord.EmbedField{{Name: "Sizes", Value: strings.Repeat("b", 500)}}}
+				assert.NoError(t, batcher.add(embed, discordMentions{}))
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, []int{2, 2, 1}, fake.messages, "messages should stay under 6000 characters")
+	})
+
+	t.Run("WithRejectedEmbed", func(t *testing.T) {
+		fake := &fakeDiscordSend{reject: "1"}
+		batcher := newDiscordBatcher(fake.send, 50*time.Millisecond)
+
+		results := addConcurrently(batcher, 3)
+		for i, err := range results {
+			if i == 1 {
+				var permanent *PermanentError
+				assert.ErrorAs(t, err, &permanent, "the invalid embed should fail")
+			} else {
+				assert.NoError(t, err, "the other embeds of the batch should be sent")
+			}
+		}
+		assert.Equal(t, []int{3, 1, 1, 1}, fake.messages, "the embeds should be sent apart once the batch is rejected")
+	})
+
+	t.Run("WithRateLimit", func(t *testing.T) {
+		response := &http.Response{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"}
+		fake := &fakeDiscordSend{err: rest.NewError(nil, nil, response, []byte(`{"retry_after": 0.5}`))}
+		batcher := newDiscordBatcher(fake.send, 10*time.Millisecond)
+
+		for _, err := range addConcurrently(batcher, 3) {
+			var retryAfter *RetryAfterError
+			assert.True(t, errors.As(err, &retryAfter), "every embed of the batch should get the error")
+		}
+		assert.Equal(t, []int{3}, fake.messages)
+	})
+}
+
+func TestDiscordNotifyerSharesWebhook(t *testing.T) {
+	first, err := NewDiscordNotifyer("https://discord.com/api/webhooks/987654321/token")
+	assert.NoError(t, err)
+	second, err := NewDiscordNotifyer("https://discord.com/api/webhooks/987654321/token")
+	assert.NoError(t, err)
+	other, err := NewDiscordNotifyer("https://discord.com/api/webhooks/123123123/token")
+	assert.NoError(t, err)
+
+	assert.Same(t, first.batcher, second.batcher, "notifyers of the same webhook should share the batcher")
+	assert.NotSame(t, first.batcher, other.batcher)
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"errors"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/rodjunger/nkmonitor"
+)
+
+// DiscordChannelNotifyer sends restocks as embeds to a channel using a bot, used by the watchlist bot to answer in the channel a product was watched from
+type DiscordChannelNotifyer struct {
+	client    rest.Channels
+	channelID snowflake.ID
+	template  *Template
+}
+
+func NewDiscordChannelNotifyer(client rest.Channels, channelID snowflake.ID) (*DiscordChannelNotifyer, error) {
+	if client == nil {
+		return nil, errors.New("nil discord client")
+	}
+	if channelID == 0 {
+		return nil, errors.New("empty discord channel")
+	}
+	return &DiscordChannelNotifyer{client: client, channelID: channelID}, nil
+}
+
+// SetTemplate changes the template of the embeds
+func (d *DiscordChannelNotifyer) SetTemplate(t *Template) {
+	d.template = t
+}
+
+func (d *DiscordChannelNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	if d == nil {
+		return errors.New("nil instance")
+	}
+
+	rendered, err := renderMessage(d.template, info)
+	if err != nil {
+		return err
+	}
+
+	return d.NotifyMessage(rendered)
+}
+
+// NotifyMessage sends message as an embed to the channel
+func (d *DiscordChannelNotifyer) NotifyMessage(message Message) error {
+	if d == nil {
+		return errors.New("nil instance")
+	}
+
+	_, err := d.client.CreateMessage(d.channelID, discord.MessageCreate{Embeds: []discord.Embed{discordEmbed(message)}})
+	if err != nil {
+		return discordError(err)
+	}
+
+	return nil
+}
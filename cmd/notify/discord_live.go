@@ -0,0 +1,143 @@
+package notify
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/disgo/webhook"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/rodjunger/nkmonitor"
+)
+
+// liveMessage is the message kept up to date for a product
+type liveMessage struct {
+	id        snowflake.ID
+	updatedAt time.Time
+}
+
+// DiscordLiveNotifyer keeps one message per product, restocks within the quiet period of the last one edit it with their sizes instead of posting a new message.
+// The monitor only reports restocks, sizes going out of stock stay in the message until the next one
+type DiscordLiveNotifyer struct {
+	create      func(embed discord.Embed) (snowflake.ID, error)
+	update      func(id snowflake.ID, embed discord.Embed) error
+	quietPeriod time.Duration
+	template    *Template
+	now         func() time.Time
+	mu          sync.Mutex
+	messages    map[string]*liveMessage // messages by product path
+}
+
+// NewDiscordLiveNotifyer creates a notifyer that edits the message of a product until it goes quietPeriod without restocking
+func NewDiscordLiveNotifyer(webhookUrl string, quietPeriod time.Duration) (*DiscordLiveNotifyer, error) {
+	id, token, err := parseWebhookUrl(webhookUrl)
+	if err != nil {
+		return nil, err
+	}
+	if quietPeriod <= 0 {
+		return nil, errors.New("quiet period must be positive")
+	}
+
+	client := webhook.New(id, token, webhook.WithRestClient(sharedDiscordRest()))
+
+	return newDiscordLiveNotifyer(func(embed discord.Embed) (snowflake.ID, error) {
+		message, err := client.CreateEmbeds([]discord.Embed{embed})
+		if err != nil {
+			return 0, err
+		}
+		return message.ID, nil
+	}, func(id snowflake.ID, embed discord.Embed) error {
+		_, err := client.UpdateEmbeds(id, []discord.Embed{embed})
+		return err
+	}, quietPeriod), nil
+}
+
+func newDiscordLiveNotifyer(create func(embed discord.Embed) (snowflake.ID, error), update func(id snowflake.ID, embed discord.Embed) error, quietPeriod time.Duration) *DiscordLiveNotifyer {
+	return &DiscordLiveNotifyer{
+		create:      create,
+		update:      update,
+		quietPeriod: quietPeriod,
+		now:         time.Now,
+		messages:    map[string]*liveMessage{},
+	}
+}
+
+// SetTemplate changes the template of the embeds
+func (d *DiscordLiveNotifyer) SetTemplate(t *Template) {
+	d.template = t
+}
+
+func (d *DiscordLiveNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	if d == nil {
+		return errors.New("nil instance")
+	}
+
+	rendered, err := renderMessage(d.template, info)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	d.forget(now)
+
+	embed := discordEmbed(d.stamp(rendered))
+	embed.Timestamp = &now
+
+	if message, ok := d.messages[info.Path]; ok {
+		err := d.update(message.id, embed)
+		if err == nil {
+			message.updatedAt = now
+			return nil
+		}
+		// The message was deleted, the product gets a new one
+		if !isUnknownMessage(err) {
+			return discordError(err)
+		}
+		delete(d.messages, info.Path)
+	}
+
+	id, err := d.create(embed)
+	if err != nil {
+		return discordError(err)
+	}
+	d.messages[info.Path] = &liveMessage{id: id, updatedAt: now}
+
+	return nil
+}
+
+// stamp adds the "last updated" label to the footer, Discord shows the timestamp of the embed after it
+func (d *DiscordLiveNotifyer) stamp(message Message) Message {
+	template := d.template
+	if template == nil {
+		template = defaultTemplate
+	}
+
+	label := template.translate("Last updated")
+	if message.Footer == "" {
+		message.Footer = label
+	} else {
+		message.Footer += " • " + label
+	}
+	return message
+}
+
+// forget drops the messages of products that have been quiet for longer than the quiet period, must be called with mu locked
+func (d *DiscordLiveNotifyer) forget(now time.Time) {
+	for path, message := range d.messages {
+		if now.Sub(message.updatedAt) >= d.quietPeriod {
+			delete(d.messages, path)
+		}
+	}
+}
+
+// isUnknownMessage reports whether err is Discord saying the message doesn't exist anymore
+func isUnknownMessage(err error) bool {
+	var restErr *rest.Error
+	return errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/disgo/rest"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDiscordMessages records the messages created and edited, new messages get increasing ids
+type fakeDiscordMessages struct {
+	created   []discord.Embed
+	updated   map[snowflake.ID][]discord.Embed
+	updateErr error
+}
+
+func (f *fakeDiscordMessages) create(embed discord.Embed) (snowflake.ID, error) {
+	f.created = append(f.created, embed)
+	return snowflake.ID(len(f.created)), nil
+}
+
+func (f *fakeDiscordMessages) update(id snowflake.ID, embed discord.Embed) error {
+	if f.updateErr != nil {
+		return f.updateErr
+	}
+	f.updated[id] = append(f.updated[id], embed)
+	return nil
+}
+
+func newTestLiveNotifyer(fake *fakeDiscordMessages, now *time.Time) *DiscordLiveNotifyer {
+	fake.updated = map[snowflake.ID][]discord.Embed{}
+	notifyer := newDiscordLiveNotifyer(fake.create, fake.update, 10*time.Minute)
+	notifyer.now = func() time.Time { return *now }
+	return notifyer
+}
+
+func TestNewDiscordLiveNotifyer(t *testing.T) {
+	_, err := NewDiscordLiveNotifyer("https://discord.com/api/webhooks/987654321/token", 0)
+	assert.EqualError(t, err, "quiet period must be positive")
+
+	_, err = NewDiscordLiveNotifyer("", time.Minute)
+	assert.EqualError(t, err, "empty webhook")
+
+	_, err = NewDiscordLiveNotifyer("https://discord.com/api/webhooks/987654321/token", time.Minute)
+	assert.NoError(t, err)
+}
+
+func TestDiscordLiveNotifyer(t *testing.T) {
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var notifyer *DiscordLiveNotifyer
+		assert.EqualError(t, notifyer.Notify(testRestock), "nil instance")
+	})
+
+	t.Run("WithinQuietPeriod", func(t *testing.T) {
+		now := time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC)
+		fake := &fakeDiscordMessages{}
+		notifyer := newTestLiveNotifyer(fake, &now)
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+		now = now.Add(5 * time.Minute)
+		assert.NoError(t, notifyer.Notify(testRestock))
+		now = now.Add(9 * time.Minute)
+		assert.NoError(t, notifyer.Notify(testRestock))
+
+		assert.Len(t, fake.created, 1, "restocks within the quiet period should edit the message")
+		assert.Len(t, fake.updated[1], 2)
+
+		last := fake.updated[1][1]
+		assert.Equal(t, now, *last.Timestamp)
+		assert.Equal(t, "Powered by the openMonitors project • Last updated", last.Footer.Text)
+	})
+
+	t.Run("AfterQuietPeriod", func(t *testing.T) {
+		now := time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC)
+		fake := &fakeDiscordMessages{}
+		notifyer := newTestLiveNotifyer(fake, &now)
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+		now = now.Add(10 * time.Minute)
+		assert.NoError(t, notifyer.Notify(testRestock))
+
+		assert.Len(t, fake.created, 2, "a restock after the quiet period should get a new message")
+		assert.Empty(t, fake.updated)
+	})
+
+	t.Run("WithOtherProduct", func(t *testing.T) {
+		now := time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC)
+		fake := &fakeDiscordMessages{}
+		notifyer := newTestLiveNotifyer(fake, &now)
+
+		other := testRestock
+		other.Path = "/tenis/other.html"
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+		assert.NoError(t, notifyer.Notify(other))
+
+		assert.Len(t, fake.created, 2, "every product should have its own message")
+	})
+
+	t.Run("WithDeletedMessage", func(t *testing.T) {
+		now := time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC)
+		fake := &fakeDiscordMessages{}
+		notifyer := newTestLiveNotifyer(fake, &now)
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+
+		response := &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found"}
+		fake.updateErr = rest.NewError(nil, nil, response, []byte(`{"code": 10008, "message": "Unknown Message"}`))
+		assert.NoError(t, notifyer.Notify(testRestock))
+
+		assert.Len(t, fake.created, 2, "a deleted message should be replaced")
+	})
+
+	t.Run("WithEditError", func(t *testing.T) {
+		now := time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC)
+		fake := &fakeDiscordMessages{}
+		notifyer := newTestLiveNotifyer(fake, &now)
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+
+		response := &http.Response{StatusCode: http.StatusForbidden, Status: "403 Forbidden"}
+		fake.updateErr = rest.NewError(nil, nil, response, nil)
+		err := notifyer.Notify(testRestock)
+
+		var permanent *PermanentError
+		assert.ErrorAs(t, err, &permanent)
+		assert.Len(t, fake.created, 1)
+	})
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"strings"
+
+	"github.com/disgoorg/disgo/discord"
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/rodjunger/nkmonitor"
+)
+
+// MentionRule pings roles and users in the Discord alerts of restocks it matches, every field that is set must match
+type MentionRule struct {
+	Roles    []snowflake.ID `json:"roles"`
+	Users    []snowflake.ID `json:"users"`
+	Sizes    []string       `json:"sizes"` // Sizes match when any of them restocked, without sizes any restocked size matches. Example: ["42", "42,5"]
+	MinPrice float64        `json:"minPrice"`
+	MaxPrice float64        `json:"maxPrice"`
+}
+
+// Match returns whether the rule pings for info, only restocked sizes are relevant
+func (r MentionRule) Match(info nkmonitor.RestockInfo) bool {
+	if r.MinPrice > 0 || r.MaxPrice > 0 {
+		price, err := parsePrice(info.Price)
+		if err != nil || price < r.MinPrice || (r.MaxPrice > 0 && price > r.MaxPrice) {
+			return false
+		}
+	}
+
+	return RestockedAny(info, r.Sizes)
+}
+
+// discordMentions are the roles and users pinged by a message
+type discordMentions struct {
+	roles []snowflake.ID
+	users []snowflake.ID
+}
+
+// mentionsFor returns the mentions of every rule matching info
+func mentionsFor(rules []MentionRule, info nkmonitor.RestockInfo) discordMentions {
+	var mentions discordMentions
+	for _, rule := range rules {
+		if rule.Match(info) {
+			mentions = mentions.merge(discordMentions{roles: rule.Roles, users: rule.Users})
+		}
+	}
+	return mentions
+}
+
+// merge returns the mentions of both, without repeating any
+func (m discordMentions) merge(other discordMentions) discordMentions {
+	return discordMentions{roles: appendMissingIDs(m.roles, other.roles), users: appendMissingIDs(m.users, other.users)}
+}
+
+func appendMissingIDs(ids []snowflake.ID, more []snowflake.ID) []snowflake.ID {
+	for _, id := range more {
+		found := false
+		for _, existing := range ids {
+			if existing == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// content returns the message content pinging the mentions
+func (m discordMentions) content() string {
+	pings := make([]string, 0, len(m.roles)+len(m.users))
+	for _, role := range m.roles {
+		pings = append(pings, discord.RoleMention(role))
+	}
+	for _, user := range m.users {
+		pings = append(pings, discord.UserMention(user))
+	}
+	return strings.Join(pings, " ")
+}
+
+// allowed only lets the mentions ping, nothing else in the message does, not even @everyone
+func (m discordMentions) allowed() *discord.AllowedMentions {
+	return &discord.AllowedMentions{
+		Parse: []discord.AllowedMentionType{},
+		Roles: append([]snowflake.ID{}, m.roles...),
+		Users: append([]snowflake.ID{}, m.users...),
+	}
+}
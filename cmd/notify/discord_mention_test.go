@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/disgoorg/snowflake/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMentionRule(t *testing.T) {
+	t.Run("WithSizes", func(t *testing.T) {
+		assert.True(t, MentionRule{Sizes: []string{"41", "42"}}.Match(testRestock))
+		assert.False(t, MentionRule{Sizes: []string{"43"}}.Match(testRestock), "sizes in stock that didn't restock should not ping")
+		assert.True(t, MentionRule{}.Match(testRestock))
+	})
+
+	t.Run("WithPrice", func(t *testing.T) {
+		assert.True(t, MentionRule{MaxPrice: 500}.Match(testRestock))
+		assert.False(t, MentionRule{MinPrice: 500}.Match(testRestock))
+		assert.False(t, MentionRule{MaxPrice: 500, Sizes: []string{"43"}}.Match(testRestock))
+	})
+
+	t.Run("WithoutRestockedSizes", func(t *testing.T) {
+		info := testRestock
+		info.Sizes = info.Sizes[1:]
+		assert.False(t, MentionRule{MaxPrice: 500}.Match(info))
+	})
+}
+
+func TestMentionsFor(t *testing.T) {
+	rules := []MentionRule{
+		{Roles: []snowflake.ID{1}, Sizes: []string{"42"}},
+		{Roles: []snowflake.ID{2, 1}, Users: []snowflake.ID{3}, MaxPrice: 500},
+		{Roles: []snowflake.ID{4}, Sizes: []string{"43"}},
+	}
+
+	mentions := mentionsFor(rules, testRestock)
+	assert.Equal(t, "<@&1> <@&2> <@3>", mentions.content())
+
+	allowed, err := json.Marshal(mentions.allowed())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"parse": [], "roles": ["1", "2"], "users": ["3"], "replied_user": false}`, string(allowed))
+
+	none, err := json.Marshal(mentionsFor(nil, testRestock).allowed())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"parse": [], "roles": [], "users": [], "replied_user": false}`, string(none), "nothing should ping without mentions")
+}
+
+func TestMentionRuleJSON(t *testing.T) {
+	var rule MentionRule
+	assert.NoError(t, json.Unmarshal([]byte(`{"roles": ["123456789012345678"], "sizes": ["42"], "maxPrice": 500}`), &rule))
+	assert.Equal(t, MentionRule{Roles: []snowflake.ID{123456789012345678}, Sizes: []string{"42"}, MaxPrice: 500}, rule)
+}
@@ -1,8 +1,14 @@
 package notify
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/disgoorg/disgo/rest"
 	"github.com/rodjunger/nkmonitor"
 	"github.com/stretchr/testify/assert"
 )
@@ -103,3 +109,46 @@ func TestNotifyu(t *testing.T) {
 		assert.Nil(t, notifyer, "Expected a nil notifyer but got a non-nil value")
 	})
 }
+
+func TestDiscordError(t *testing.T) {
+	response := func(status int, header http.Header) *http.Response {
+		return &http.Response{StatusCode: status, Status: http.StatusText(status), Header: header}
+	}
+
+	t.Run("WithRateLimit", func(t *testing.T) {
+		err := discordError(rest.NewError(nil, nil, response(http.StatusTooManyRequests, http.Header{"Retry-After": {"2"}}), []byte(`{"message": "You are being rate limited.", "retry_after": 1.5, "global": false}`)))
+
+		var retryAfter *RetryAfterError
+		assert.ErrorAs(t, err, &retryAfter)
+		assert.Equal(t, 1500*time.Millisecond, retryAfter.After)
+	})
+
+	t.Run("WithRateLimitHeaderOnly", func(t *testing.T) {
+		err := discordError(rest.NewError(nil, nil, response(http.StatusTooManyRequests, http.Header{"Retry-After": {"2"}}), nil))
+
+		var retryAfter *RetryAfterError
+		assert.ErrorAs(t, err, &retryAfter)
+		assert.Equal(t, 2*time.Second, retryAfter.After)
+	})
+
+	t.Run("WithUnknownWebhook", func(t *testing.T) {
+		err := discordError(rest.NewError(nil, nil, response(http.StatusNotFound, nil), []byte(`{"message": "Unknown Webhook", "code": 10015}`)))
+
+		var permanent *PermanentError
+		assert.ErrorAs(t, err, &permanent)
+	})
+
+	t.Run("WithServerError", func(t *testing.T) {
+		original := rest.NewError(nil, nil, response(http.StatusBadGateway, nil), nil)
+		assert.Equal(t, original, discordError(original))
+	})
+
+	t.Run("WithRequestError", func(t *testing.T) {
+		err := discordError(fmt.Errorf("error doing request in rest client: %w", &url.Error{
+			Op:  "Post",
+			URL: "https://discord.com/api/v10/webhooks/1234567890/secret-token?wait=true",
+			Err: errors.New("dial tcp: lookup discord.com: no such host"),
+		}))
+		assert.EqualError(t, err, "dial tcp: lookup discord.com: no such host", "the webhook token should be left out")
+	})
+}
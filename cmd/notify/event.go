@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+)
+
+// EventVersion is the version of the Event schema, bumped on breaking changes
+const EventVersion = 1
+
+// KindRestock is the kind of events created from a restock
+const KindRestock = "restock"
+
+// Event is the JSON document sent by machine-readable notifyers (webhooks, message brokers...)
+type Event struct {
+	Version    int          `json:"version"`
+	Kind       string       `json:"kind"`
+	TaskID     string       `json:"taskId"`
+	DetectedAt time.Time    `json:"detectedAt"`
+	SentAt     time.Time    `json:"sentAt"`
+	Product    EventProduct `json:"product"`
+}
+
+type EventProduct struct {
+	Path     string      `json:"path"`
+	Url      string      `json:"url"`
+	Name     string      `json:"name"`
+	NickName string      `json:"nickName"`
+	Code     string      `json:"code"`
+	Price    string      `json:"price"`
+	Picture  string      `json:"picture"`
+	Sizes    []EventSize `json:"sizes"`
+}
+
+type EventSize struct {
+	Description string `json:"description"`
+	Sku         string `json:"sku"`
+	Ean         string `json:"ean"`
+	HasStock    bool   `json:"hasStock"`
+	IsAvailable bool   `json:"isAvailable"`
+	Restocked   bool   `json:"restocked"`
+	Links       []Link `json:"links,omitempty"` // Links are the quick links of the template, only sizes that can be added to cart have them
+}
+
+// NewEvent converts a restock to an Event with the quick links of the default template, SentAt is set to the current time
+func NewEvent(info nkmonitor.RestockInfo) Event {
+	// The links of the default template always render
+	event, _ := newEvent(nil, info)
+	return event
+}
+
+// newEvent converts a restock to an Event with the quick links of t, the default template if it's nil
+func newEvent(t *Template, info nkmonitor.RestockInfo) (Event, error) {
+	if t == nil {
+		t = defaultTemplate
+	}
+
+	links, err := renderLinks(t.links, t.sizeParam, newTemplateData(info, KindRestock))
+	if err != nil {
+		return Event{}, err
+	}
+	linksBySize := make(map[string][]Link, len(links))
+	for _, size := range links {
+		linksBySize[size.Size] = size.Links
+	}
+
+	sizes := make([]EventSize, 0, len(info.Sizes))
+	for _, size := range info.Sizes {
+		if size == nil {
+			continue
+		}
+		sizes = append(sizes, EventSize{
+			Description: size.Description,
+			Sku:         size.Sku,
+			Ean:         size.Ean,
+			HasStock:    size.HasStock,
+			IsAvailable: size.IsAvailable,
+			Restocked:   size.Restocked,
+			Links:       linksBySize[size.Description],
+		})
+	}
+
+	return Event{
+		Version:    EventVersion,
+		Kind:       KindRestock,
+		TaskID:     info.TaskID,
+		DetectedAt: info.DetectedAt,
+		SentAt:     time.Now(),
+		Product: EventProduct{
+			Path:     info.Path,
+			Url:      productUrl(info),
+			Name:     info.Name,
+			NickName: info.NickName,
+			Code:     info.Code,
+			Price:    info.Price,
+			Picture:  info.Picture,
+			Sizes:    sizes,
+		},
+	}, nil
+}
+
+// renderEventTemplate replaces {styleCode} and {event} in template, escaping the values with escaper
+func renderEventTemplate(template string, event Event, escaper *strings.Replacer) string {
+	styleCode := event.Product.Code
+	if styleCode == "" {
+		styleCode = "unknown"
+	}
+
+	return strings.NewReplacer(
+		"{styleCode}", escaper.Replace(styleCode),
+		"{event}", escaper.Replace(event.Kind),
+	).Replace(template)
+}
+
+// eventKey is used to keep the events of a product together, the style code or the path if it's unknown
+func eventKey(event Event) string {
+	if event.Product.Code != "" {
+		return event.Product.Code
+	}
+	return event.Product.Path
+}
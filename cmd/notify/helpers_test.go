@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordedRequest is a request received by a recordingServer, with its JSON body decoded
+type recordedRequest struct {
+	Path   string
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// recordingServer records the requests it gets and answers them with respond
+type recordingServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+// respondWith answers every request with status and body
+func respondWith(status int, body string) func(recordedRequest) (int, string) {
+	return func(recordedRequest) (int, string) {
+		return status, body
+	}
+}
+
+func newRecordingServer(t *testing.T, respond func(request recordedRequest) (status int, body string)) *recordingServer {
+	s := &recordingServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := recordedRequest{Path: r.URL.Path, Header: r.Header.Clone()}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request.Body))
+
+		s.mu.Lock()
+		s.requests = append(s.requests, request)
+		s.mu.Unlock()
+
+		status, body := respond(request)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// all returns the requests received so far, in order
+func (s *recordingServer) all() []recordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]recordedRequest{}, s.requests...)
+}
+
+// last returns the last request received, the zero value if there were none
+func (s *recordingServer) last() recordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return recordedRequest{}
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// paths returns the paths of the requests received so far, in order
+func (s *recordingServer) paths() []string {
+	var paths []string
+	for _, request := range s.all() {
+		paths = append(paths, request.Path)
+	}
+	return paths
+}
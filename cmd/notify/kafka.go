@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+)
+
+const defaultKafkaTimeout = 10 * time.Second
+
+// KafkaOptions configures a KafkaNotifyer
+type KafkaOptions struct {
+	Brokers  []string      // Brokers used to discover the partitions of the topic, example: localhost:9092
+	Topic    string        // Topic events are produced to
+	Username string        // Username and Password authenticate with SASL/PLAIN when set
+	Password string        //
+	TLS      bool          // TLS connects to the brokers with TLS
+	Timeout  time.Duration // Timeout of connecting and producing, defaults to 10s
+}
+
+// kafkaProducer is the subset of the Kafka client used by KafkaNotifyer
+type kafkaProducer interface {
+	produce(key, value []byte) error
+	close()
+}
+
+type kafkaWriter struct {
+	writer  *kafka.Writer
+	timeout time.Duration
+}
+
+func (k *kafkaWriter) produce(key, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), k.timeout)
+	defer cancel()
+	return k.writer.WriteMessages(ctx, kafka.Message{Key: key, Value: value})
+}
+
+func (k *kafkaWriter) close() {
+	k.writer.Close()
+}
+
+// KafkaNotifyer produces restocks as JSON Events to a Kafka topic.
+// Records are keyed by style code so the events of a product land in order on the same partition
+type KafkaNotifyer struct {
+	producer kafkaProducer
+	template *Template
+}
+
+// NewKafkaNotifyer creates a notifyer that produces to options.Topic, the brokers are contacted in the background
+func NewKafkaNotifyer(options KafkaOptions) (*KafkaNotifyer, error) {
+	var brokers []string
+	for _, broker := range options.Brokers {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	if len(brokers) == 0 {
+		return nil, errors.New("empty kafka brokers")
+	}
+
+	if options.Topic == "" {
+		return nil, errors.New("empty kafka topic")
+	}
+
+	if options.Timeout <= 0 {
+		options.Timeout = defaultKafkaTimeout
+	}
+
+	dialer := &kafka.Dialer{ClientID: "nkmonitor", Timeout: options.Timeout, DualStack: true}
+	if options.TLS {
+		dialer.TLS = &tls.Config{}
+	}
+	if options.Username != "" {
+		dialer.SASLMechanism = plain.Mechanism{Username: options.Username, Password: options.Password}
+	}
+
+	writer := kafka.NewWriter(kafka.WriterConfig{
+		Brokers: brokers,
+		Topic:   options.Topic,
+		Dialer:  dialer,
+		// Same partitioner as the Java producer, so consumers can rely on the usual key to partition mapping
+		Balancer: &kafka.Murmur2Balancer{},
+		// Restocks are produced one at a time, waiting for a batch would only delay them
+		BatchSize: 1,
+		// The queue in front of the notifyer retries failures
+		MaxAttempts:  1,
+		ReadTimeout:  options.Timeout,
+		WriteTimeout: options.Timeout,
+	})
+
+	return newKafkaNotifyer(&kafkaWriter{writer: writer, timeout: options.Timeout}), nil
+}
+
+func newKafkaNotifyer(producer kafkaProducer) *KafkaNotifyer {
+	return &KafkaNotifyer{producer: producer}
+}
+
+// SetTemplate changes the template whose quick links are added to the events
+func (k *KafkaNotifyer) SetTemplate(t *Template) {
+	k.template = t
+}
+
+func (k *KafkaNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	if k == nil {
+		return errors.New("nil instance")
+	}
+
+	event, err := newEvent(k.template, info)
+	if err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if err := k.producer.produce([]byte(eventKey(event)), value); err != nil {
+		return fmt.Errorf("kafka: %w", kafkaError(err))
+	}
+
+	return nil
+}
+
+// kafkaError marks the errors of the brokers that won't go away by retrying, like a record that is too large or a denied topic
+func kafkaError(err error) error {
+	var brokerErr kafka.Error
+	if errors.As(err, &brokerErr) && !brokerErr.Temporary() {
+		return &PermanentError{Err: err}
+	}
+	return err
+}
+
+// Close flushes the pending records and disconnects from the brokers
+func (k *KafkaNotifyer) Close() {
+	k.producer.close()
+}
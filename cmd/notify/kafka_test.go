@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type producedRecord struct {
+	key   string
+	value []byte
+}
+
+type fakeKafkaProducer struct {
+	records []producedRecord
+	err     error
+}
+
+func (f *fakeKafkaProducer) produce(key, value []byte) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.records = append(f.records, producedRecord{string(key), value})
+	return nil
+}
+
+func (f *fakeKafkaProducer) close() {}
+
+func TestNewKafkaNotifyer(t *testing.T) {
+	_, err := NewKafkaNotifyer(KafkaOptions{Brokers: []string{""}, Topic: "restocks"})
+	assert.EqualError(t, err, "empty kafka brokers")
+
+	_, err = NewKafkaNotifyer(KafkaOptions{Brokers: []string{"localhost:9092"}})
+	assert.EqualError(t, err, "empty kafka topic")
+
+	t.Run("WithBrokerDown", func(t *testing.T) {
+		notifyer, err := NewKafkaNotifyer(KafkaOptions{Brokers: []string{"127.0.0.1:1"}, Topic: "restocks", Timeout: time.Second})
+		assert.NoError(t, err, "brokers should be contacted in the background")
+		defer notifyer.Close()
+
+		assert.ErrorContains(t, notifyer.Notify(testRestock), "kafka: ")
+	})
+}
+
+func TestKafkaNotifyer(t *testing.T) {
+	t.Run("WithKeyedRecord", func(t *testing.T) {
+		producer := &fakeKafkaProducer{}
+		notifyer := newKafkaNotifyer(producer)
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+		assert.Len(t, producer.records, 1)
+		assert.Equal(t, "ABC123", producer.records[0].key)
+
+		var decoded Event
+		assert.NoError(t, json.Unmarshal(producer.records[0].value, &decoded))
+		assert.Equal(t, testRestock.Name, decoded.Product.Name)
+	})
+
+	t.Run("WithoutStyleCode", func(t *testing.T) {
+		producer := &fakeKafkaProducer{}
+		notifyer := newKafkaNotifyer(producer)
+
+		info := testRestock
+		info.Code = ""
+		assert.NoError(t, notifyer.Notify(info))
+		assert.Equal(t, testRestock.Path, producer.records[0].key, "path should be the key when the style code is unknown")
+	})
+
+	t.Run("WithProduceError", func(t *testing.T) {
+		notifyer := newKafkaNotifyer(&fakeKafkaProducer{err: errors.New("unknown topic or partition")})
+		assert.EqualError(t, notifyer.Notify(testRestock), "kafka: unknown topic or partition")
+	})
+
+	t.Run("WithBrokerError", func(t *testing.T) {
+		var permanent *PermanentError
+
+		notifyer := newKafkaNotifyer(&fakeKafkaProducer{err: kafka.TopicAuthorizationFailed})
+		assert.ErrorAs(t, notifyer.Notify(testRestock), &permanent)
+
+		notifyer = newKafkaNotifyer(&fakeKafkaProducer{err: kafka.LeaderNotAvailable})
+		assert.False(t, errors.As(notifyer.Notify(testRestock), &permanent), "temporary errors should be retried")
+	})
+
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var notifyer *KafkaNotifyer
+		assert.EqualError(t, notifyer.Notify(nkmonitor.RestockInfo{}), "nil instance")
+	})
+}
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/rodjunger/nkmonitor"
+)
+
+// LinkTemplate is a quick link added to every size that can be added to cart, Name and Url are text/template templates executed with a LinkData
+type LinkTemplate struct {
+	Name string `json:"name"` // Name labels the link, example: Task
+	Url  string `json:"url"`  // Url of the link, example: https://tool.example/task?sku={{query .Size.Sku}}
+}
+
+// LinkData is what link templates are executed with, the fields of TemplateData can be used directly, like {{.Code}}
+type LinkData struct {
+	TemplateData
+	Size    *nkmonitor.SizeInfo
+	SizeUrl string // SizeUrl is the url of the product page selecting the size with MessageTemplate.SizeParam, empty when it's not set
+}
+
+// Link is a rendered LinkTemplate
+type Link struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+// SizeLinks are the quick links of a size
+type SizeLinks struct {
+	Size  string
+	Links []Link
+}
+
+type compiledLink struct {
+	name *template.Template
+	url  *template.Template
+}
+
+// sizeUrl returns the url of the product page of info with size in the query parameter param.
+// It's empty if param is, so links to it are left out instead of all going to the same page
+func sizeUrl(info nkmonitor.RestockInfo, size *nkmonitor.SizeInfo, param string) string {
+	if param == "" {
+		return ""
+	}
+	return productUrl(info) + "?" + url.Values{param: {size.Description}}.Encode()
+}
+
+// renderLinks executes the link templates for every size of data that can be added to cart, links rendering to an empty url are left out
+func renderLinks(links []compiledLink, sizeParam string, data TemplateData) ([]SizeLinks, error) {
+	if len(links) == 0 {
+		return nil, nil
+	}
+
+	var rendered []SizeLinks
+	for _, size := range data.Sizes {
+		if size == nil || !size.IsAvailable {
+			continue
+		}
+
+		linkData := LinkData{TemplateData: data, Size: size, SizeUrl: sizeUrl(data.RestockInfo, size, sizeParam)}
+		sizeLinks := SizeLinks{Size: size.Description}
+		for _, link := range links {
+			linkUrl, err := executeLink(link.url, linkData)
+			if err != nil {
+				return nil, err
+			}
+			if linkUrl == "" {
+				continue
+			}
+			name, err := executeLink(link.name, linkData)
+			if err != nil {
+				return nil, err
+			}
+			sizeLinks.Links = append(sizeLinks.Links, Link{Name: name, Url: linkUrl})
+		}
+
+		if len(sizeLinks.Links) > 0 {
+			rendered = append(rendered, sizeLinks)
+		}
+	}
+
+	return rendered, nil
+}
+
+func executeLink(tmpl *template.Template, data LinkData) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// linkLines formats the links of every size as a line, escape is applied to the sizes and format lays out a single link.
+// Lines that would make the text longer than limit are left out
+func linkLines(links []SizeLinks, escape func(text string) string, format func(link Link) string, limit int) string {
+	var sb strings.Builder
+	for _, size := range links {
+		formatted := make([]string, len(size.Links))
+		for i, link := range size.Links {
+			formatted[i] = format(link)
+		}
+		line := escape(size.Size) + ": " + strings.Join(formatted, " · ")
+
+		if sb.Len() > 0 {
+			line = "\n" + line
+		}
+		if sb.Len()+len(line) > limit {
+			break
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/stretchr/testify/assert"
+)
+
+// tamanhoTemplate is the default template with sizes selected by the tamanho parameter
+var tamanhoTemplate = func() *Template {
+	source := DefaultMessageTemplate
+	source.SizeParam = "tamanho"
+	return MustTemplate(source)
+}()
+
+func TestLinkTemplates(t *testing.T) {
+	t.Run("WithCustomLinks", func(t *testing.T) {
+		source := DefaultMessageTemplate
+		source.SizeParam = "tamanho"
+		source.Links = []LinkTemplate{
+			{Name: "{{.Size.Description}}", Url: "{{.SizeUrl}}"},
+			{Name: "Task", Url: "https://tool.example/task?sku={{query .Size.Sku}}&code={{.Code}}"},
+			{Name: "Skipped", Url: "{{if .Size.Restocked}}{{end}}"},
+		}
+		template, err := NewTemplate(source)
+		assert.NoError(t, err)
+
+		info := testRestock
+		info.Sizes = append(info.Sizes, &nkmonitor.SizeInfo{Description: "35,5", Sku: "333 4", IsAvailable: true})
+
+		message, err := template.Render(info, KindRestock)
+		assert.NoError(t, err)
+		assert.Equal(t, []SizeLinks{
+			{Size: "42", Links: []Link{
+				{Name: "42", Url: "https://www.nike.com.br/tenis/test.html?tamanho=42"},
+				{Name: "Task", Url: "https://tool.example/task?sku=111&code=ABC123"},
+			}},
+			{Size: "35,5", Links: []Link{
+				{Name: "35,5", Url: "https://www.nike.com.br/tenis/test.html?tamanho=35%2C5"},
+				{Name: "Task", Url: "https://tool.example/task?sku=333+4&code=ABC123"},
+			}},
+		}, message.Links, "links rendering to an empty url should be left out")
+	})
+
+	t.Run("WithoutSizeParam", func(t *testing.T) {
+		message, err := renderMessage(nil, testRestock)
+		assert.NoError(t, err)
+		assert.Empty(t, message.Links, "sizes should not all link to the same product page")
+		assert.NotContains(t, plainText(message), "Quick links")
+	})
+
+	t.Run("WithoutLinks", func(t *testing.T) {
+		source := DefaultMessageTemplate
+		source.Links = nil
+		template, err := NewTemplate(source)
+		assert.NoError(t, err)
+
+		message, err := template.Render(testRestock, KindRestock)
+		assert.NoError(t, err)
+		assert.Empty(t, message.Links)
+		assert.NotContains(t, plainText(message), "Quick links")
+	})
+
+	t.Run("WithInvalidLink", func(t *testing.T) {
+		_, err := NewTemplate(MessageTemplate{Links: []LinkTemplate{{Name: "Task", Url: "{{.Size"}}})
+		assert.ErrorContains(t, err, "invalid link 0 url template")
+	})
+}
+
+func TestLinkFormats(t *testing.T) {
+	message, err := renderMessage(tamanhoTemplate, testRestock)
+	assert.NoError(t, err)
+
+	embed := discordEmbed(message)
+	last := embed.Fields[len(embed.Fields)-1]
+	assert.Equal(t, "Quick links", last.Name)
+	assert.Equal(t, "**42**: [Product](https://www.nike.com.br/tenis/test.html?tamanho=42)", last.Value)
+
+	assert.Contains(t, telegramMessage(message), "<b>Quick links</b>\n42: <a href=\"https://www.nike.com.br/tenis/test.html?tamanho=42\">Product</a>\n")
+
+	event, err := newEvent(tamanhoTemplate, testRestock)
+	assert.NoError(t, err)
+	assert.Equal(t, []Link{{Name: "Product", Url: "https://www.nike.com.br/tenis/test.html?tamanho=42"}}, event.Product.Sizes[0].Links)
+	assert.Empty(t, event.Product.Sizes[1].Links, "sizes that can't be added to cart should not have links")
+	assert.Empty(t, NewEvent(testRestock).Product.Sizes[0].Links)
+}
+
+func TestLinkLines(t *testing.T) {
+	links := []SizeLinks{
+		{Size: "40", Links: []Link{{Name: "A", Url: "a"}, {Name: "B", Url: "b"}}},
+		{Size: "41", Links: []Link{{Name: "A", Url: "a"}}},
+	}
+	format := func(link Link) string { return link.Name + "=" + link.Url }
+	same := func(text string) string { return text }
+
+	assert.Equal(t, "40: A=a · B=b\n41: A=a", linkLines(links, same, format, 100))
+	assert.Equal(t, "40: A=a · B=b", linkLines(links, same, format, len("40: A=a · B=b")+3), "lines that don't fit should be left out")
+	assert.Empty(t, linkLines(links, same, format, 5))
+	assert.Equal(t, "<40>: A=a", strings.Split(linkLines(links, func(text string) string { return "<" + text + ">" }, format, 100), " ·")[0])
+}
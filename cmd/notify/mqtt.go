@@ -0,0 +1,155 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rodjunger/nkmonitor"
+)
+
+const (
+	DefaultMQTTTopic      = "nkmonitor/{styleCode}/{event}"
+	DefaultMQTTStateTopic = "nkmonitor/{styleCode}/state"
+	defaultMQTTTimeout    = 10 * time.Second
+)
+
+// topicEscaper replaces the characters that can't be used in a topic level
+var topicEscaper = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+// MQTTOptions configures a MQTTNotifyer
+type MQTTOptions struct {
+	Broker     string // Broker url, example: tcp://localhost:1883, ssl://broker:8883 or ws://broker:80/mqtt
+	ClientID   string
+	Username   string
+	Password   string
+	Topic      string        // Topic every event is published to, {styleCode} and {event} are replaced. Defaults to DefaultMQTTTopic
+	StateTopic string        // StateTopic gets the latest event of each product as a retained message. Defaults to DefaultMQTTStateTopic
+	NoState    bool          // NoState disables the retained state messages
+	QoS        byte          // QoS is 0, 1 or 2
+	Timeout    time.Duration // Timeout of connecting and publishing, defaults to 10s
+}
+
+// mqttPublisher is the subset of the MQTT client used by MQTTNotifyer
+type mqttPublisher interface {
+	publish(topic string, qos byte, retained bool, payload []byte) error
+	close()
+}
+
+type pahoPublisher struct {
+	client  mqtt.Client
+	timeout time.Duration
+}
+
+func (p *pahoPublisher) publish(topic string, qos byte, retained bool, payload []byte) error {
+	token := p.client.Publish(topic, qos, retained, payload)
+	if !token.WaitTimeout(p.timeout) {
+		return errors.New("mqtt: publish timed out")
+	}
+	return token.Error()
+}
+
+func (p *pahoPublisher) close() {
+	p.client.Disconnect(250)
+}
+
+// MQTTNotifyer publishes restocks as JSON Events to a MQTT broker
+type MQTTNotifyer struct {
+	options   MQTTOptions
+	publisher mqttPublisher
+	template  *Template
+}
+
+// NewMQTTNotifyer connects to the broker and returns a notifyer that publishes to it, reconnecting automatically
+func NewMQTTNotifyer(options MQTTOptions) (*MQTTNotifyer, error) {
+	if options.Broker == "" {
+		return nil, errors.New("empty mqtt broker")
+	}
+
+	if options.QoS > 2 {
+		return nil, errors.New("invalid mqtt qos")
+	}
+
+	if options.Timeout <= 0 {
+		options.Timeout = defaultMQTTTimeout
+	}
+
+	if options.ClientID == "" {
+		options.ClientID = fmt.Sprintf("nkmonitor-%d", time.Now().UnixNano())
+	}
+
+	clientOptions := mqtt.NewClientOptions().
+		AddBroker(options.Broker).
+		SetClientID(options.ClientID).
+		SetUsername(options.Username).
+		SetPassword(options.Password).
+		SetConnectTimeout(options.Timeout).
+		SetWriteTimeout(options.Timeout).
+		SetAutoReconnect(true)
+
+	client := mqtt.NewClient(clientOptions)
+	token := client.Connect()
+	if !token.WaitTimeout(options.Timeout) {
+		return nil, errors.New("mqtt: connection timed out")
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("mqtt: %w", err)
+	}
+
+	return newMQTTNotifyer(options, &pahoPublisher{client: client, timeout: options.Timeout}), nil
+}
+
+func newMQTTNotifyer(options MQTTOptions, publisher mqttPublisher) *MQTTNotifyer {
+	if options.Topic == "" {
+		options.Topic = DefaultMQTTTopic
+	}
+	if options.StateTopic == "" {
+		options.StateTopic = DefaultMQTTStateTopic
+	}
+	return &MQTTNotifyer{options: options, publisher: publisher}
+}
+
+// renderTopic replaces the placeholders of a topic template
+func renderTopic(template string, event Event) string {
+	return renderEventTemplate(template, event, topicEscaper)
+}
+
+// SetTemplate changes the template whose quick links are added to the events
+func (m *MQTTNotifyer) SetTemplate(t *Template) {
+	m.template = t
+}
+
+func (m *MQTTNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	if m == nil {
+		return errors.New("nil instance")
+	}
+
+	event, err := newEvent(m.template, info)
+	if err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if err := m.publisher.publish(renderTopic(m.options.Topic, event), m.options.QoS, false, payload); err != nil {
+		return err
+	}
+
+	if !m.options.NoState {
+		// Retained so subscribers that connect later get the current stock of every product right away
+		return m.publisher.publish(renderTopic(m.options.StateTopic, event), m.options.QoS, true, payload)
+	}
+
+	return nil
+}
+
+// Close disconnects from the broker
+func (m *MQTTNotifyer) Close() {
+	m.publisher.close()
+}
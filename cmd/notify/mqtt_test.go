@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/stretchr/testify/assert"
+)
+
+type publishedMessage struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  []byte
+}
+
+type fakePublisher struct {
+	messages []publishedMessage
+	err      error
+}
+
+func (f *fakePublisher) publish(topic string, qos byte, retained bool, payload []byte) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.messages = append(f.messages, publishedMessage{topic, qos, retained, payload})
+	return nil
+}
+
+func (f *fakePublisher) close() {}
+
+func TestNewMQTTNotifyer(t *testing.T) {
+	_, err := NewMQTTNotifyer(MQTTOptions{})
+	assert.EqualError(t, err, "empty mqtt broker")
+
+	_, err = NewMQTTNotifyer(MQTTOptions{Broker: "tcp://localhost:1883", QoS: 3})
+	assert.EqualError(t, err, "invalid mqtt qos")
+}
+
+func TestRenderTopic(t *testing.T) {
+	event := NewEvent(testRestock)
+	assert.Equal(t, "nkmonitor/ABC123/restock", renderTopic(DefaultMQTTTopic, event))
+
+	event.Product.Code = "A/B+C#"
+	assert.Equal(t, "alerts/A_B_C_", renderTopic("alerts/{styleCode}", event), "wildcards and separators should be escaped")
+
+	event.Product.Code = ""
+	assert.Equal(t, "nkmonitor/unknown/state", renderTopic(DefaultMQTTStateTopic, event))
+}
+
+func TestMQTTNotify(t *testing.T) {
+	t.Run("WithState", func(t *testing.T) {
+		publisher := &fakePublisher{}
+		notifyer := newMQTTNotifyer(MQTTOptions{QoS: 1}, publisher)
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+		assert.Len(t, publisher.messages, 2)
+
+		event, state := publisher.messages[0], publisher.messages[1]
+		assert.Equal(t, "nkmonitor/ABC123/restock", event.topic)
+		assert.False(t, event.retained)
+		assert.Equal(t, byte(1), event.qos)
+		assert.Equal(t, "nkmonitor/ABC123/state", state.topic)
+		assert.True(t, state.retained, "state should be retained for late subscribers")
+
+		var decoded Event
+		assert.NoError(t, json.Unmarshal(state.payload, &decoded))
+		assert.Equal(t, testRestock.Code, decoded.Product.Code)
+	})
+
+	t.Run("WithoutState", func(t *testing.T) {
+		publisher := &fakePublisher{}
+		notifyer := newMQTTNotifyer(MQTTOptions{NoState: true, Topic: "restocks/{event}"}, publisher)
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+		assert.Len(t, publisher.messages, 1)
+		assert.Equal(t, "restocks/restock", publisher.messages[0].topic)
+	})
+
+	t.Run("WithPublishError", func(t *testing.T) {
+		notifyer := newMQTTNotifyer(MQTTOptions{}, &fakePublisher{err: errors.New("not connected")})
+		assert.EqualError(t, notifyer.Notify(testRestock), "not connected")
+	})
+
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var notifyer *MQTTNotifyer
+		assert.EqualError(t, notifyer.Notify(nkmonitor.RestockInfo{}), "nil instance")
+	})
+}
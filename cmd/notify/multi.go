@@ -0,0 +1,171 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rodjunger/nkmonitor"
+)
+
+// Rule selects restocks, every field that is set must match
+type Rule struct {
+	Paths      []string `json:"paths"`      // Paths are path.Match patterns of the product path, example: /snkrs/*
+	StyleCodes []string `json:"styleCodes"` // StyleCodes are path.Match patterns of the style code, example: DD1391-*
+	Kinds      []string `json:"kinds"`      // Kinds of event, example: restock
+	MinPrice   float64  `json:"minPrice"`
+	MaxPrice   float64  `json:"maxPrice"`
+	Sizes      []string `json:"sizes"` // Sizes match when any of them restocked, example: ["42", "42,5"]
+}
+
+// matchAny returns whether value matches any of the path.Match patterns
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// RestockedAny returns whether any of sizes restocked in info, sizes that were already in stock don't count.
+// Any restocked size matches when sizes is empty
+func RestockedAny(info nkmonitor.RestockInfo, sizes []string) bool {
+	for _, size := range info.Sizes {
+		if size == nil || !size.Restocked {
+			continue
+		}
+		if len(sizes) == 0 {
+			return true
+		}
+		for _, wanted := range sizes {
+			if size.Description == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parsePrice converts a price in the site format (R$ 1.299,99) to a number
+func parsePrice(price string) (float64, error) {
+	price = strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == ',' {
+			return r
+		}
+		return -1
+	}, price)
+	return strconv.ParseFloat(strings.Replace(price, ",", ".", 1), 64)
+}
+
+// Match returns whether the rule selects the event of kind created from info
+func (r Rule) Match(info nkmonitor.RestockInfo, kind string) bool {
+	if len(r.Paths) > 0 && !matchAny(r.Paths, info.Path) {
+		return false
+	}
+
+	if len(r.StyleCodes) > 0 && !matchAny(r.StyleCodes, info.Code) {
+		return false
+	}
+
+	if len(r.Kinds) > 0 && !matchAny(r.Kinds, kind) {
+		return false
+	}
+
+	if r.MinPrice > 0 || r.MaxPrice > 0 {
+		price, err := parsePrice(info.Price)
+		if err != nil || price < r.MinPrice || (r.MaxPrice > 0 && price > r.MaxPrice) {
+			return false
+		}
+	}
+
+	if len(r.Sizes) > 0 && !RestockedAny(info, r.Sizes) {
+		return false
+	}
+
+	return true
+}
+
+// Route is a notifyer and the rules that select what it's sent
+type Route struct {
+	Name     string
+	Notifyer Notifyer
+	Rules    []Rule // Rules are alternatives, the route gets the restocks matching any of them, or every restock if there are none
+}
+
+// Match returns whether the route should get the event of kind created from info
+func (r Route) Match(info nkmonitor.RestockInfo, kind string) bool {
+	if len(r.Rules) == 0 {
+		return true
+	}
+
+	for _, rule := range r.Rules {
+		if rule.Match(info, kind) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MultiError has the errors of every route that failed
+type MultiError []error
+
+func (m MultiError) Error() string {
+	messages := make([]string, len(m))
+	for i, err := range m {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// MultiNotifyer fans restocks out to the routes they match
+type MultiNotifyer struct {
+	routes []Route
+}
+
+// NewMultiNotifyer creates a notifyer that sends to the matching routes
+func NewMultiNotifyer(routes ...Route) *MultiNotifyer {
+	return &MultiNotifyer{routes: routes}
+}
+
+// Notify sends info to every matching route at the same time, waiting for all of them.
+// The returned error is a MultiError with the routes that failed
+func (m *MultiNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	if m == nil {
+		return errors.New("nil instance")
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed MultiError
+	)
+
+	for _, route := range m.routes {
+		if !route.Match(info, KindRestock) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(route Route) {
+			defer wg.Done()
+			if err := route.Notifyer.Notify(info); err != nil {
+				mu.Lock()
+				failed = append(failed, fmt.Errorf("%s: %w", route.Name, err))
+				mu.Unlock()
+			}
+		}(route)
+	}
+
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return failed
+	}
+
+	return nil
+}
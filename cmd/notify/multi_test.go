@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingNotifyer keeps every restock and message it's sent
+type recordingNotifyer struct {
+	mu       sync.Mutex
+	restocks []nkmonitor.RestockInfo
+	messages []Message
+	err      error
+	closed   int
+}
+
+func (r *recordingNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.restocks = append(r.restocks, info)
+	return r.err
+}
+
+func (r *recordingNotifyer) NotifyMessage(message Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, message)
+	return r.err
+}
+
+func (r *recordingNotifyer) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed++
+}
+
+func TestParsePrice(t *testing.T) {
+	price, err := parsePrice("R$ 1.299,99")
+	assert.NoError(t, err)
+	assert.Equal(t, 1299.99, price)
+
+	price, err = parsePrice("R$ 100,00")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(100), price)
+
+	_, err = parsePrice("Indisponível")
+	assert.Error(t, err)
+}
+
+func TestRuleMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  Rule
+		match bool
+	}{
+		{"WithEmptyRule", Rule{}, true},
+		{"WithMatchingPath", Rule{Paths: []string{"/tenis/*"}}, true},
+		{"WithOtherPath", Rule{Paths: []string{"/snkrs/*"}}, false},
+		{"WithMatchingStyleCode", Rule{StyleCodes: []string{"XYZ*", "ABC*"}}, true},
+		{"WithOtherStyleCode", Rule{StyleCodes: []string{"XYZ*"}}, false},
+		{"WithMatchingKind", Rule{Kinds: []string{KindRestock}}, true},
+		{"WithOtherKind", Rule{Kinds: []string{"test"}}, false},
+		{"WithPriceInRange", Rule{MinPrice: 50, MaxPrice: 100}, true},
+		{"WithPriceBelowMin", Rule{MinPrice: 150}, false},
+		{"WithPriceAboveMax", Rule{MaxPrice: 99.99}, false},
+		{"WithMatchingSize", Rule{Sizes: []string{"40", "42"}}, true},
+		{"WithOtherSizes", Rule{Sizes: []string{"40", "41"}}, false},
+		{"WithSizeNotRestocked", Rule{Sizes: []string{"43"}}, false},
+		{"WithOneFieldNotMatching", Rule{Paths: []string{"/tenis/*"}, Sizes: []string{"40"}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.match, test.rule.Match(testRestock, KindRestock))
+		})
+	}
+}
+
+func TestMultiNotifyer(t *testing.T) {
+	t.Run("WithRoutes", func(t *testing.T) {
+		all, snkrs, cheap := &recordingNotifyer{}, &recordingNotifyer{}, &recordingNotifyer{}
+		notifyer := NewMultiNotifyer(
+			Route{Name: "all", Notifyer: all},
+			Route{Name: "snkrs", Notifyer: snkrs, Rules: []Rule{{Paths: []string{"/snkrs/*"}}}},
+			Route{Name: "cheap", Notifyer: cheap, Rules: []Rule{{Paths: []string{"/snkrs/*"}}, {MaxPrice: 200}}},
+		)
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+		assert.Len(t, all.restocks, 1)
+		assert.Empty(t, snkrs.restocks)
+		assert.Len(t, cheap.restocks, 1, "any of the rules should match")
+	})
+
+	t.Run("WithFailingRoute", func(t *testing.T) {
+		working := &recordingNotifyer{}
+		notifyer := NewMultiNotifyer(
+			Route{Name: "broken", Notifyer: &recordingNotifyer{err: errors.New("HTTP status 500")}},
+			Route{Name: "working", Notifyer: working},
+		)
+
+		err := notifyer.Notify(testRestock)
+		assert.EqualError(t, err, "broken: HTTP status 500")
+		assert.IsType(t, MultiError{}, err)
+		assert.Len(t, working.restocks, 1, "a failing route shouldn't stop the others")
+	})
+
+	t.Run("WithoutRoutes", func(t *testing.T) {
+		assert.NoError(t, NewMultiNotifyer().Notify(testRestock))
+	})
+
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var notifyer *MultiNotifyer
+		assert.EqualError(t, notifyer.Notify(nkmonitor.RestockInfo{}), "nil instance")
+	})
+}
@@ -0,0 +1,165 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/rodjunger/nkmonitor"
+)
+
+const (
+	DefaultNATSSubject = "nkmonitor.{event}.{styleCode}"
+	defaultNATSTimeout = 10 * time.Second
+)
+
+// subjectEscaper replaces the characters that can't be used in a subject token
+var subjectEscaper = strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_")
+
+// NATSOptions configures a NATSNotifyer
+type NATSOptions struct {
+	Url       string // Url of the server, example: nats://localhost:4222, comma separated for a cluster
+	Subject   string // Subject template, {styleCode} and {event} are replaced. Defaults to DefaultNATSSubject
+	JetStream bool   // JetStream waits for a stream to acknowledge every event, a stream capturing the subject must exist
+	Username  string
+	Password  string
+	Token     string
+	CredsFile string        // CredsFile is a .creds file with the user JWT and seed
+	Timeout   time.Duration // Timeout of connecting and publishing, defaults to 10s
+}
+
+// natsPublisher is the subset of the NATS client used by NATSNotifyer
+type natsPublisher interface {
+	publish(subject string, payload []byte, msgID string) error
+	close()
+}
+
+type corePublisher struct {
+	conn    *nats.Conn
+	timeout time.Duration
+}
+
+func (c *corePublisher) publish(subject string, payload []byte, msgID string) error {
+	if err := c.conn.Publish(subject, payload); err != nil {
+		return err
+	}
+	// Core NATS is fire and forget, flushing at least makes sure the server got it
+	return c.conn.FlushTimeout(c.timeout)
+}
+
+func (c *corePublisher) close() {
+	c.conn.Close()
+}
+
+type jetStreamPublisher struct {
+	conn *nats.Conn
+	js   nats.JetStreamContext
+}
+
+func (j *jetStreamPublisher) publish(subject string, payload []byte, msgID string) error {
+	// The message id lets the stream drop duplicates if a publish is retried
+	_, err := j.js.Publish(subject, payload, nats.MsgId(msgID))
+	return err
+}
+
+func (j *jetStreamPublisher) close() {
+	j.conn.Close()
+}
+
+// NATSNotifyer publishes restocks as JSON Events to NATS, each product gets its own subject so its events stay in order
+type NATSNotifyer struct {
+	options   NATSOptions
+	publisher natsPublisher
+	template  *Template
+}
+
+// NewNATSNotifyer connects to the server and returns a notifyer that publishes to it, reconnecting automatically
+func NewNATSNotifyer(options NATSOptions) (*NATSNotifyer, error) {
+	if options.Url == "" {
+		return nil, errors.New("empty nats url")
+	}
+
+	if options.Timeout <= 0 {
+		options.Timeout = defaultNATSTimeout
+	}
+
+	natsOptions := []nats.Option{
+		nats.Name("nkmonitor"),
+		nats.Timeout(options.Timeout),
+		nats.MaxReconnects(-1),
+	}
+	if options.Username != "" {
+		natsOptions = append(natsOptions, nats.UserInfo(options.Username, options.Password))
+	}
+	if options.Token != "" {
+		natsOptions = append(natsOptions, nats.Token(options.Token))
+	}
+	if options.CredsFile != "" {
+		natsOptions = append(natsOptions, nats.UserCredentials(options.CredsFile))
+	}
+
+	conn, err := nats.Connect(options.Url, natsOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+
+	if !options.JetStream {
+		return newNATSNotifyer(options, &corePublisher{conn: conn, timeout: options.Timeout}), nil
+	}
+
+	js, err := conn.JetStream(nats.MaxWait(options.Timeout))
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+
+	return newNATSNotifyer(options, &jetStreamPublisher{conn: conn, js: js}), nil
+}
+
+func newNATSNotifyer(options NATSOptions, publisher natsPublisher) *NATSNotifyer {
+	if options.Subject == "" {
+		options.Subject = DefaultNATSSubject
+	}
+	return &NATSNotifyer{options: options, publisher: publisher}
+}
+
+// natsMsgID identifies an event for JetStream deduplication
+func natsMsgID(event Event) string {
+	return fmt.Sprintf("%s-%s-%d", event.Kind, eventKey(event), event.DetectedAt.UnixNano())
+}
+
+// SetTemplate changes the template whose quick links are added to the events
+func (n *NATSNotifyer) SetTemplate(t *Template) {
+	n.template = t
+}
+
+func (n *NATSNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	if n == nil {
+		return errors.New("nil instance")
+	}
+
+	event, err := newEvent(n.template, info)
+	if err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	subject := renderEventTemplate(n.options.Subject, event, subjectEscaper)
+	if err := n.publisher.publish(subject, payload, natsMsgID(event)); err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+
+	return nil
+}
+
+// Close disconnects from the server
+func (n *NATSNotifyer) Close() {
+	n.publisher.close()
+}
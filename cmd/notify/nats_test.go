@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/stretchr/testify/assert"
+)
+
+type natsMessage struct {
+	subject string
+	payload []byte
+	msgID   string
+}
+
+type fakeNATSPublisher struct {
+	messages []natsMessage
+	err      error
+}
+
+func (f *fakeNATSPublisher) publish(subject string, payload []byte, msgID string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.messages = append(f.messages, natsMessage{subject, payload, msgID})
+	return nil
+}
+
+func (f *fakeNATSPublisher) close() {}
+
+func TestNewNATSNotifyer(t *testing.T) {
+	_, err := NewNATSNotifyer(NATSOptions{})
+	assert.EqualError(t, err, "empty nats url")
+}
+
+func TestNATSNotify(t *testing.T) {
+	t.Run("WithDefaultSubject", func(t *testing.T) {
+		publisher := &fakeNATSPublisher{}
+		notifyer := newNATSNotifyer(NATSOptions{}, publisher)
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+		assert.NoError(t, notifyer.Notify(testRestock))
+		assert.Len(t, publisher.messages, 2)
+
+		message := publisher.messages[0]
+		assert.Equal(t, "nkmonitor.restock.ABC123", message.subject)
+		assert.Equal(t, message.msgID, publisher.messages[1].msgID, "the same restock should be deduplicated by JetStream")
+
+		var decoded Event
+		assert.NoError(t, json.Unmarshal(message.payload, &decoded))
+		assert.Equal(t, testRestock.Code, decoded.Product.Code)
+	})
+
+	t.Run("WithEscapedStyleCode", func(t *testing.T) {
+		publisher := &fakeNATSPublisher{}
+		notifyer := newNATSNotifyer(NATSOptions{Subject: "shop.{styleCode}"}, publisher)
+
+		info := testRestock
+		info.Code = "AB.1 *>"
+		assert.NoError(t, notifyer.Notify(info))
+		assert.Equal(t, "shop.AB_1___", publisher.messages[0].subject)
+	})
+
+	t.Run("WithPublishError", func(t *testing.T) {
+		notifyer := newNATSNotifyer(NATSOptions{}, &fakeNATSPublisher{err: errors.New("nats: no response from stream")})
+		assert.EqualError(t, notifyer.Notify(testRestock), "nats: nats: no response from stream")
+	})
+
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var notifyer *NATSNotifyer
+		assert.EqualError(t, notifyer.Notify(nkmonitor.RestockInfo{}), "nil instance")
+	})
+}
@@ -1,7 +1,79 @@
 package notify
 
-import "github.com/rodjunger/nkmonitor"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+
+	"github.com/rodjunger/nkmonitor"
+)
 
 type Notifyer interface {
 	Notify(info nkmonitor.RestockInfo) error
 }
+
+// productUrl returns the url of the product page
+func productUrl(info nkmonitor.RestockInfo) string {
+	return "https://www.nike.com.br" + info.Path
+}
+
+// splitSizes formats the sizes of a restock with sizeToString, split into sizes that can be added to cart and sizes that are only in stock
+func splitSizes(info nkmonitor.RestockInfo) (availableSizes, inStockSizes []string) {
+	for i := range info.Sizes { //Using i to avoid problems with reused loop variable and pointers
+		infoStr := sizeToString(info.Sizes[i])
+		if info.Sizes[i].IsAvailable {
+			availableSizes = append(availableSizes, infoStr)
+		} else {
+			inStockSizes = append(inStockSizes, infoStr)
+		}
+	}
+	return availableSizes, inStockSizes
+}
+
+// plainText formats a message as plain text, for notifyers without rich formatting
+func plainText(message Message) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%s\n%s\n", message.Title, message.Url)
+	if message.Description != "" {
+		fmt.Fprintf(&sb, "\n%s\n", message.Description)
+	}
+
+	inline := false
+	for _, field := range message.Fields {
+		if !field.Inline {
+			fmt.Fprintf(&sb, "\n%s\n%s\n", field.Name, field.Value)
+			inline = false
+			continue
+		}
+		// Inline fields are grouped in a paragraph
+		if !inline {
+			sb.WriteString("\n")
+			inline = true
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", field.Name, field.Value)
+	}
+
+	if len(message.Links) > 0 {
+		fmt.Fprintf(&sb, "\n%s\n%s\n", message.LinksTitle, linkLines(message.Links, func(text string) string { return text }, func(link Link) string {
+			return link.Name + " " + link.Url
+		}, math.MaxInt))
+	}
+
+	if message.Footer != "" {
+		fmt.Fprintf(&sb, "\n%s\n", message.Footer)
+	}
+
+	return sb.String()
+}
+
+// StripUrl returns the underlying error of url errors, used when the url has secrets (tokens, webhook ids...) that must not be logged
+func StripUrl(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
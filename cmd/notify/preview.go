@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+)
+
+// PreviewFormats are the formats Preview can render
+var PreviewFormats = []string{"text", "discord", "telegram", "slack", "email"}
+
+// SampleRestock is a made up restock used to preview and test notifications
+var SampleRestock = nkmonitor.RestockInfo{
+	Path:     "/tenis/nike-dunk-low-retro-masculino-026148.html",
+	Name:     "Tênis Nike Dunk Low Retro Masculino",
+	NickName: "Dunk Low Retro",
+	Code:     "DD1391-100",
+	Price:    "R$ 899,99",
+	Picture:  "https://imgnike-a.akamaihd.net/1920x1920/026148ID.jpg",
+	Sizes: []*nkmonitor.SizeInfo{
+		{Description: "40", Sku: "026148ID-40", HasStock: true, IsAvailable: true, Restocked: true},
+		{Description: "41", Sku: "026148ID-41", HasStock: true, IsAvailable: true},
+		{Description: "42", Sku: "026148ID-42", HasStock: true},
+	},
+	TaskID:     "sample",
+	DetectedAt: time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC),
+}
+
+// Preview renders info with t as it would be sent to a destination of format, the default template is used if t is nil
+func Preview(format string, t *Template, info nkmonitor.RestockInfo) (string, error) {
+	message, err := renderMessage(t, info)
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case "text":
+		return plainText(message), nil
+	case "telegram":
+		return telegramMessage(message), nil
+	case "discord":
+		return indentedJSON(discordEmbed(message))
+	case "slack":
+		return indentedJSON(slackBlocks(message))
+	case "email":
+		var html bytes.Buffer
+		if err := smtpHtmlTemplate.Execute(&html, message); err != nil {
+			return "", err
+		}
+		return html.String(), nil
+	default:
+		return "", fmt.Errorf("unknown preview format %q", format)
+	}
+}
+
+func indentedJSON(v interface{}) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
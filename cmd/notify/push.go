@@ -0,0 +1,236 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+)
+
+const (
+	ntfyDefaultPriority   = 3
+	ntfyHighPriority      = 4
+	gotifyDefaultPriority = 5
+	gotifyHighPriority    = 8
+)
+
+// NtfyNotifyer publishes restocks to a ntfy topic
+type NtfyNotifyer struct {
+	serverUrl string
+	topic     string
+	token     string
+	client    *http.Client
+	template  *Template
+}
+
+// GotifyNotifyer publishes restocks to a Gotify application
+type GotifyNotifyer struct {
+	serverUrl string
+	token     string
+	client    *http.Client
+	template  *Template
+}
+
+func validateServerUrl(serverUrl string) (string, error) {
+	parsed, err := url.Parse(serverUrl)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return "", errors.New("invalid server url")
+	}
+	return strings.TrimSuffix(serverUrl, "/"), nil
+}
+
+// postJSON POSTs payload to target, failing on non 2xx responses, see statusError
+func postJSON(client *http.Client, target string, headers http.Header, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header = headers.Clone()
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return StripUrl(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return statusError(resp.StatusCode, resp.Header, respBody)
+	}
+
+	return nil
+}
+
+// NewNtfyNotifyer creates a notifyer that publishes to topic on a ntfy server, token is optional
+func NewNtfyNotifyer(serverUrl, topic, token string) (*NtfyNotifyer, error) {
+	serverUrl, err := validateServerUrl(serverUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if topic == "" {
+		return nil, errors.New("empty ntfy topic")
+	}
+
+	return &NtfyNotifyer{serverUrl: serverUrl, topic: topic, token: token, client: &http.Client{Timeout: 20 * time.Second}}, nil
+}
+
+// ntfyMessage is the JSON publishing format of ntfy
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Click    string   `json:"click"`
+	Attach   string   `json:"attach,omitempty"`
+	Tags     []string `json:"tags"`
+}
+
+// SetTemplate changes the template of the notifications
+func (n *NtfyNotifyer) SetTemplate(t *Template) {
+	n.template = t
+}
+
+func (n *NtfyNotifyer) message(rendered Message) ntfyMessage {
+	priority := ntfyDefaultPriority
+	if rendered.Available {
+		priority = ntfyHighPriority
+	}
+
+	return ntfyMessage{
+		Topic:    n.topic,
+		Title:    rendered.Title,
+		Message:  plainText(rendered),
+		Priority: priority,
+		Click:    rendered.Url,
+		Attach:   rendered.Picture,
+		Tags:     []string{"athletic_shoe"},
+	}
+}
+
+func (n *NtfyNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	if n == nil {
+		return errors.New("nil instance")
+	}
+
+	rendered, err := renderMessage(n.template, info)
+	if err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+
+	return n.NotifyMessage(rendered)
+}
+
+// NotifyMessage publishes message to the topic
+func (n *NtfyNotifyer) NotifyMessage(rendered Message) error {
+	if n == nil {
+		return errors.New("nil instance")
+	}
+
+	headers := http.Header{}
+	if n.token != "" {
+		headers.Set("Authorization", "Bearer "+n.token)
+	}
+
+	message := n.message(rendered)
+
+	// Publishing as JSON is done to the root of the server, the topic goes in the body
+	if err := postJSON(n.client, n.serverUrl+"/", headers, message); err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+
+	return nil
+}
+
+// NewGotifyNotifyer creates a notifyer that publishes to the Gotify application identified by token
+func NewGotifyNotifyer(serverUrl, token string) (*GotifyNotifyer, error) {
+	serverUrl, err := validateServerUrl(serverUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if token == "" {
+		return nil, errors.New("empty gotify token")
+	}
+
+	return &GotifyNotifyer{serverUrl: serverUrl, token: token, client: &http.Client{Timeout: 20 * time.Second}}, nil
+}
+
+type gotifyMessage struct {
+	Title    string                 `json:"title"`
+	Message  string                 `json:"message"`
+	Priority int                    `json:"priority"`
+	Extras   map[string]interface{} `json:"extras"`
+}
+
+// SetTemplate changes the template of the notifications
+func (g *GotifyNotifyer) SetTemplate(t *Template) {
+	g.template = t
+}
+
+func (g *GotifyNotifyer) message(rendered Message) gotifyMessage {
+	priority := gotifyDefaultPriority
+	if rendered.Available {
+		priority = gotifyHighPriority
+	}
+
+	notification := map[string]interface{}{}
+	if rendered.Url != "" {
+		notification["click"] = map[string]string{"url": rendered.Url}
+	}
+	if rendered.Picture != "" {
+		notification["bigImageUrl"] = rendered.Picture
+	}
+
+	return gotifyMessage{
+		Title:    rendered.Title,
+		Message:  plainText(rendered),
+		Priority: priority,
+		Extras: map[string]interface{}{
+			"client::notification": notification,
+			"client::display":      map[string]string{"contentType": "text/plain"},
+		},
+	}
+}
+
+func (g *GotifyNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	if g == nil {
+		return errors.New("nil instance")
+	}
+
+	rendered, err := renderMessage(g.template, info)
+	if err != nil {
+		return fmt.Errorf("gotify: %w", err)
+	}
+
+	return g.NotifyMessage(rendered)
+}
+
+// NotifyMessage sends message to the application
+func (g *GotifyNotifyer) NotifyMessage(rendered Message) error {
+	if g == nil {
+		return errors.New("nil instance")
+	}
+
+	message := g.message(rendered)
+	headers := http.Header{"X-Gotify-Key": {g.token}}
+	if err := postJSON(g.client, g.serverUrl+"/message", headers, message); err != nil {
+		return fmt.Errorf("gotify: %w", err)
+	}
+
+	return nil
+}
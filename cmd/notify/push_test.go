@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNtfyNotifyer(t *testing.T) {
+	_, err := NewNtfyNotifyer("ntfy.sh", "restocks", "")
+	assert.Error(t, err, "url without scheme should be invalid")
+
+	_, err = NewNtfyNotifyer("https://ntfy.sh", "", "")
+	assert.Error(t, err, "empty topic should be invalid")
+
+	t.Run("WithAvailableSizes", func(t *testing.T) {
+		server := newRecordingServer(t, respondWith(http.StatusOK, ""))
+		notifyer, err := NewNtfyNotifyer(server.URL+"/", "restocks", "tk_abc")
+		assert.NoError(t, err)
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+		assert.Equal(t, "/", server.last().Path)
+		assert.Equal(t, "Bearer tk_abc", server.last().Header.Get("Authorization"))
+		assert.Equal(t, "restocks", server.last().Body["topic"])
+		assert.Equal(t, float64(ntfyHighPriority), server.last().Body["priority"])
+		assert.Equal(t, "https://www.nike.com.br/tenis/test.html", server.last().Body["click"])
+		assert.Equal(t, testRestock.Picture, server.last().Body["attach"])
+	})
+
+	t.Run("WithInStockSizesOnly", func(t *testing.T) {
+		server := newRecordingServer(t, respondWith(http.StatusOK, ""))
+		notifyer, _ := NewNtfyNotifyer(server.URL, "restocks", "")
+
+		info := testRestock
+		info.Sizes = info.Sizes[1:]
+		assert.NoError(t, notifyer.Notify(info))
+		assert.Empty(t, server.last().Header.Get("Authorization"))
+		assert.Equal(t, float64(ntfyDefaultPriority), server.last().Body["priority"])
+	})
+
+	t.Run("WithServerError", func(t *testing.T) {
+		server := newRecordingServer(t, respondWith(http.StatusForbidden, ""))
+		notifyer, _ := NewNtfyNotifyer(server.URL, "restocks", "")
+		err := notifyer.Notify(testRestock)
+		assert.EqualError(t, err, "ntfy: HTTP status 403: ")
+
+		var permanent *PermanentError
+		assert.ErrorAs(t, err, &permanent, "client errors shouldn't be retried")
+	})
+
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var notifyer *NtfyNotifyer
+		assert.EqualError(t, notifyer.Notify(nkmonitor.RestockInfo{}), "nil instance")
+	})
+}
+
+func TestGotifyNotifyer(t *testing.T) {
+	_, err := NewGotifyNotifyer("https://gotify.example.com", "")
+	assert.Error(t, err, "empty token should be invalid")
+
+	t.Run("WithAvailableSizes", func(t *testing.T) {
+		server := newRecordingServer(t, respondWith(http.StatusOK, ""))
+		notifyer, err := NewGotifyNotifyer(server.URL, "app-token")
+		assert.NoError(t, err)
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+		assert.Equal(t, "/message", server.last().Path)
+		assert.Equal(t, "app-token", server.last().Header.Get("X-Gotify-Key"))
+		assert.Equal(t, float64(gotifyHighPriority), server.last().Body["priority"])
+
+		notification := server.last().Body["extras"].(map[string]interface{})["client::notification"].(map[string]interface{})
+		assert.Equal(t, "https://www.nike.com.br/tenis/test.html", notification["click"].(map[string]interface{})["url"])
+		assert.Equal(t, testRestock.Picture, notification["bigImageUrl"])
+	})
+
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var notifyer *GotifyNotifyer
+		assert.EqualError(t, notifyer.Notify(nkmonitor.RestockInfo{}), "nil instance")
+	})
+}
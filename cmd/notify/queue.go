@@ -0,0 +1,236 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+)
+
+const (
+	defaultQueueAttempts  = 5
+	defaultQueueBaseDelay = time.Second
+	defaultQueueMaxDelay  = time.Minute
+	defaultQueueSize      = 100
+)
+
+var (
+	ErrQueueFull   = errors.New("notification queue full")
+	ErrQueueClosed = errors.New("notification queue closed")
+)
+
+// RetryAfterError is returned by notifyers when the destination asked to wait before retrying, like a HTTP 429
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+func (r *RetryAfterError) Error() string {
+	return r.Err.Error()
+}
+
+func (r *RetryAfterError) Unwrap() error {
+	return r.Err
+}
+
+// PermanentError is returned by notifyers when retrying won't help, like an unknown webhook
+type PermanentError struct {
+	Err error
+}
+
+func (p *PermanentError) Error() string {
+	return p.Err.Error()
+}
+
+func (p *PermanentError) Unwrap() error {
+	return p.Err
+}
+
+// statusError returns the error of a HTTP response that failed with status, marking it for the queue:
+// rate limits are retried after the wait in the Retry-After header and other client errors are permanent
+func statusError(status int, header http.Header, body []byte) error {
+	err := fmt.Errorf("HTTP status %v: %s", status, body)
+
+	switch {
+	case status == http.StatusTooManyRequests:
+		seconds, _ := strconv.Atoi(header.Get("Retry-After"))
+		return &RetryAfterError{Err: err, After: time.Duration(seconds) * time.Second}
+	case status == http.StatusRequestTimeout:
+		return err
+	case status >= 400 && status < 500:
+		return &PermanentError{Err: err}
+	default:
+		return err
+	}
+}
+
+// QueueOptions configures a Queue
+type QueueOptions struct {
+	MaxAttempts int                                      // MaxAttempts is the number of tries before giving up on a restock, defaults to 5
+	BaseDelay   time.Duration                            // BaseDelay is the wait after the first failure, doubled after every other one. Defaults to 1s
+	MaxDelay    time.Duration                            // MaxDelay caps the wait between tries, defaults to 1m
+	Size        int                                      // Size is the number of restocks waiting to be sent before new ones are rejected, defaults to 100
+	Workers     int                                      // Workers is the number of restocks sent at the same time, defaults to 1
+	DeadLetters *DeadLetterFile                          // DeadLetters gets the restocks that permanently failed, optional
+	OnError     func(attempt int, err error, final bool) // OnError is called after every failed try, optional
+}
+
+// Queue sends restocks to a notifyer in the background, retrying failures with exponential backoff and jitter
+type Queue struct {
+	name     string
+	notifyer Notifyer
+	options  QueueOptions
+	restocks chan nkmonitor.RestockInfo
+	done     chan struct{}
+	closed   bool
+	mu       sync.RWMutex
+}
+
+// NewQueue starts a queue in front of notifyer, name identifies it in the dead letters
+func NewQueue(name string, notifyer Notifyer, options QueueOptions) *Queue {
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = defaultQueueAttempts
+	}
+	if options.BaseDelay <= 0 {
+		options.BaseDelay = defaultQueueBaseDelay
+	}
+	if options.MaxDelay <= 0 {
+		options.MaxDelay = defaultQueueMaxDelay
+	}
+	if options.Size <= 0 {
+		options.Size = defaultQueueSize
+	}
+	if options.Workers <= 0 {
+		options.Workers = 1
+	}
+
+	q := &Queue{
+		name:     name,
+		notifyer: notifyer,
+		options:  options,
+		restocks: make(chan nkmonitor.RestockInfo, options.Size),
+		done:     make(chan struct{}),
+	}
+
+	var workers sync.WaitGroup
+	workers.Add(options.Workers)
+	for i := 0; i < options.Workers; i++ {
+		go func() {
+			defer workers.Done()
+			q.run()
+		}()
+	}
+
+	go func() {
+		workers.Wait()
+		close(q.done)
+	}()
+
+	return q
+}
+
+// Notify queues info to be sent, it only fails if the queue is full or closed
+func (q *Queue) Notify(info nkmonitor.RestockInfo) error {
+	if q == nil {
+		return errors.New("nil instance")
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.restocks <- info:
+		return nil
+	default:
+		q.deadLetter(info, 0, ErrQueueFull)
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting restocks, waits for the queued ones to be sent and closes the notifyer if it has a Close method
+func (q *Queue) Close() {
+	q.mu.Lock()
+	first := !q.closed
+	if first {
+		q.closed = true
+		close(q.restocks)
+	}
+	q.mu.Unlock()
+
+	<-q.done
+	if closer, ok := q.notifyer.(interface{ Close() }); ok && first {
+		closer.Close()
+	}
+}
+
+func (q *Queue) run() {
+	for info := range q.restocks {
+		q.deliver(info)
+	}
+}
+
+// deliver tries to send info until it succeeds, fails permanently or runs out of attempts
+func (q *Queue) deliver(info nkmonitor.RestockInfo) {
+	for attempt := 1; ; attempt++ {
+		err := q.notifyer.Notify(info)
+		if err == nil {
+			return
+		}
+
+		var permanent *PermanentError
+		final := attempt >= q.options.MaxAttempts || errors.As(err, &permanent)
+
+		if q.options.OnError != nil {
+			q.options.OnError(attempt, err, final)
+		}
+
+		if final {
+			q.deadLetter(info, attempt, err)
+			return
+		}
+
+		time.Sleep(q.backoff(attempt, err))
+	}
+}
+
+// backoff returns the wait after the failed attempt, the wait asked by the destination if there is one
+func (q *Queue) backoff(attempt int, err error) time.Duration {
+	var retryAfter *RetryAfterError
+	if errors.As(err, &retryAfter) && retryAfter.After > 0 {
+		return retryAfter.After
+	}
+
+	delay := q.options.BaseDelay << (attempt - 1)
+	if delay > q.options.MaxDelay || delay <= 0 {
+		delay = q.options.MaxDelay
+	}
+
+	// Waiting between half and the full delay keeps queues that failed together from retrying together
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (q *Queue) deadLetter(info nkmonitor.RestockInfo, attempts int, err error) {
+	if q.options.DeadLetters == nil {
+		return
+	}
+
+	writeErr := q.options.DeadLetters.Write(DeadLetter{
+		Notifier: q.name,
+		FailedAt: time.Now(),
+		Attempts: attempts,
+		Error:    err.Error(),
+		Restock:  info,
+	})
+	if writeErr != nil && q.options.OnError != nil {
+		q.options.OnError(attempts, writeErr, true)
+	}
+}
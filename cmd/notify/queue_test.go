@@ -0,0 +1,174 @@
+package notify
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyNotifyer fails with errs in order, then succeeds
+type flakyNotifyer struct {
+	recordingNotifyer
+	errs []error
+}
+
+func (f *flakyNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	f.recordingNotifyer.Notify(info)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *flakyNotifyer) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.restocks)
+}
+
+func TestQueue(t *testing.T) {
+	t.Run("WithRetries", func(t *testing.T) {
+		notifyer := &flakyNotifyer{errs: []error{errors.New("timeout"), errors.New("HTTP status 502")}}
+		deadLetters := filepath.Join(t.TempDir(), "dlq.jsonl")
+		queue := NewQueue("discord", notifyer, QueueOptions{BaseDelay: time.Millisecond, DeadLetters: NewDeadLetterFile(deadLetters)})
+
+		assert.NoError(t, queue.Notify(testRestock))
+		queue.Close()
+
+		assert.Equal(t, 3, notifyer.calls())
+		assert.NoFileExists(t, deadLetters)
+	})
+
+	t.Run("WithAttemptsExhausted", func(t *testing.T) {
+		notifyer := &flakyNotifyer{errs: []error{errors.New("timeout"), errors.New("timeout"), errors.New("timeout")}}
+		deadLetters := filepath.Join(t.TempDir(), "dlq.jsonl")
+
+		var finalErrors int
+		queue := NewQueue("discord", notifyer, QueueOptions{
+			MaxAttempts: 2,
+			BaseDelay:   time.Millisecond,
+			DeadLetters: NewDeadLetterFile(deadLetters),
+			OnError: func(attempt int, err error, final bool) {
+				if final {
+					finalErrors++
+				}
+			},
+		})
+
+		assert.NoError(t, queue.Notify(testRestock))
+		queue.Close()
+
+		assert.Equal(t, 2, notifyer.calls())
+		assert.Equal(t, 1, finalErrors)
+
+		letters, err := ReadDeadLetters(deadLetters)
+		assert.NoError(t, err)
+		assert.Len(t, letters, 1)
+		assert.Equal(t, "discord", letters[0].Notifier)
+		assert.Equal(t, 2, letters[0].Attempts)
+		assert.Equal(t, "timeout", letters[0].Error)
+		assert.Equal(t, testRestock.Code, letters[0].Restock.Code)
+		assert.Equal(t, "42", letters[0].Restock.Sizes[0].Description)
+	})
+
+	t.Run("WithPermanentError", func(t *testing.T) {
+		notifyer := &flakyNotifyer{errs: []error{&PermanentError{Err: errors.New("unknown webhook")}}}
+		deadLetters := filepath.Join(t.TempDir(), "dlq.jsonl")
+		queue := NewQueue("discord", notifyer, QueueOptions{BaseDelay: time.Millisecond, DeadLetters: NewDeadLetterFile(deadLetters)})
+
+		assert.NoError(t, queue.Notify(testRestock))
+		queue.Close()
+
+		assert.Equal(t, 1, notifyer.calls(), "permanent errors shouldn't be retried")
+		assert.FileExists(t, deadLetters)
+	})
+
+	t.Run("WithClosedQueue", func(t *testing.T) {
+		notifyer := &flakyNotifyer{}
+		queue := NewQueue("discord", notifyer, QueueOptions{})
+		queue.Close()
+		queue.Close()
+		assert.ErrorIs(t, queue.Notify(testRestock), ErrQueueClosed)
+		assert.Equal(t, 1, notifyer.closed, "the notifyer should be closed once")
+	})
+
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var queue *Queue
+		assert.EqualError(t, queue.Notify(nkmonitor.RestockInfo{}), "nil instance")
+	})
+}
+
+func TestQueueBackoff(t *testing.T) {
+	queue := &Queue{options: QueueOptions{BaseDelay: time.Second, MaxDelay: 10 * time.Second}}
+
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		delay := queue.backoff(attempt+1, errors.New("timeout"))
+		assert.GreaterOrEqual(t, delay, max/2, "attempt %d", attempt+1)
+		assert.LessOrEqual(t, delay, max, "attempt %d", attempt+1)
+	}
+
+	retryAfter := &RetryAfterError{Err: errors.New("rate limited"), After: 42 * time.Second}
+	assert.Equal(t, 42*time.Second, queue.backoff(1, retryAfter), "the wait asked by the destination should be used")
+}
+
+func TestDeadLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	file := NewDeadLetterFile(path)
+
+	assert.NoError(t, file.Write(DeadLetter{Notifier: "discord", Restock: testRestock}))
+	assert.NoError(t, file.Write(DeadLetter{Notifier: "slack", Restock: testRestock}))
+
+	letters, err := ReadDeadLetters(path)
+	assert.NoError(t, err)
+	assert.Len(t, letters, 2)
+
+	assert.NoError(t, WriteDeadLetters(path, letters[1:]))
+	letters, err = ReadDeadLetters(path)
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, "slack", letters[0].Notifier)
+
+	assert.NoError(t, WriteDeadLetters(path, nil))
+	assert.NoFileExists(t, path, "the file should be removed when every restock was resent")
+}
+
+func TestQueueWorkers(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 3)
+	notifyer := notifyerFunc(func(info nkmonitor.RestockInfo) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+
+	queue := NewQueue("discord", notifyer, QueueOptions{Workers: 3})
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, queue.Notify(testRestock))
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("restocks should be sent at the same time")
+		}
+	}
+
+	close(release)
+	queue.Close()
+}
+
+type notifyerFunc func(info nkmonitor.RestockInfo) error
+
+func (f notifyerFunc) Notify(info nkmonitor.RestockInfo) error {
+	return f(info)
+}
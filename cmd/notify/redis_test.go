@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/rodjunger/nkmonitor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRedisMode(t *testing.T) {
+	mode, err := ParseRedisMode("pubsub")
+	assert.NoError(t, err)
+	assert.Equal(t, RedisPubSub, mode)
+
+	_, err = ParseRedisMode("list")
+	assert.EqualError(t, err, `invalid redis mode "list"`)
+}
+
+func TestRedisNotifyer(t *testing.T) {
+	_, err := NewRedisNotifyer(RedisOptions{})
+	assert.EqualError(t, err, "empty redis url")
+
+	_, err = NewRedisNotifyer(RedisOptions{Url: "http://localhost:6379"})
+	assert.Error(t, err, "url without redis scheme should be invalid")
+
+	t.Run("WithStream", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		notifyer, err := NewRedisNotifyer(RedisOptions{Url: "redis://" + server.Addr(), MaxLen: 1})
+		assert.NoError(t, err)
+		defer notifyer.Close()
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+		assert.NoError(t, notifyer.Notify(testRestock))
+
+		entries, err := server.Stream(DefaultRedisKey)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, entries)
+
+		values := entries[len(entries)-1].Values
+		assert.Equal(t, []string{"event", values[1], "kind", KindRestock, "styleCode", "ABC123"}, values)
+
+		var decoded Event
+		assert.NoError(t, json.Unmarshal([]byte(values[1]), &decoded))
+		assert.Equal(t, EventVersion, decoded.Version)
+		assert.Equal(t, testRestock.Code, decoded.Product.Code)
+	})
+
+	t.Run("WithPubSub", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		notifyer, err := NewRedisNotifyer(RedisOptions{Url: "redis://" + server.Addr(), Mode: RedisPubSub, Key: "restocks"})
+		assert.NoError(t, err)
+		defer notifyer.Close()
+
+		subscriber := server.NewSubscriber()
+		defer subscriber.Close()
+		subscriber.Subscribe("restocks")
+
+		// The subscriber channel is unbuffered, so it has to be read while the message is published
+		result := make(chan error, 1)
+		go func() { result <- notifyer.Notify(testRestock) }()
+
+		message := <-subscriber.Messages()
+		assert.NoError(t, <-result)
+		assert.Equal(t, "restocks", message.Channel)
+
+		var decoded Event
+		assert.NoError(t, json.Unmarshal([]byte(message.Message), &decoded))
+		assert.Equal(t, KindRestock, decoded.Kind)
+	})
+
+	t.Run("WithQueue", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		notifyer, err := NewRedisNotifyer(RedisOptions{Url: "redis://" + server.Addr()})
+		assert.NoError(t, err)
+
+		NewQueue("redis", notifyer, QueueOptions{}).Close()
+		assert.Error(t, notifyer.Notify(testRestock), "closing the queue should close the connections to Redis")
+	})
+
+	t.Run("WithServerDown", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		notifyer, err := NewRedisNotifyer(RedisOptions{Url: "redis://" + server.Addr()})
+		assert.NoError(t, err)
+		defer notifyer.Close()
+
+		server.Close()
+		assert.Error(t, notifyer.Notify(testRestock))
+	})
+
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var notifyer *RedisNotifyer
+		assert.EqualError(t, notifyer.Notify(nkmonitor.RestockInfo{}), "nil instance")
+	})
+}
@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+)
+
+// slackTextLimit is the maximum length of the text of a section block
+const slackTextLimit = 3000
+
+// slackEscaper escapes the characters that have a special meaning in Slack mrkdwn
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+type SlackNotifyer struct {
+	webhookUrl string
+	client     *http.Client
+	template   *Template
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type      string            `json:"type"`
+	Text      *slackText        `json:"text,omitempty"`
+	Fields    []slackText       `json:"fields,omitempty"`
+	Accessory *slackImage       `json:"accessory,omitempty"`
+	Elements  []json.RawMessage `json:"elements,omitempty"`
+}
+
+type slackImage struct {
+	Type     string `json:"type"`
+	ImageUrl string `json:"image_url"`
+	AltText  string `json:"alt_text"`
+}
+
+type slackMessage struct {
+	Text   string       `json:"text"` // Text is the fallback shown in notifications
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// NewSlackNotifyer creates a notifyer that posts restocks to a Slack incoming webhook
+func NewSlackNotifyer(webhookUrl string) (*SlackNotifyer, error) {
+	if webhookUrl == "" {
+		return nil, errors.New("empty webhook")
+	}
+
+	parsed, err := url.Parse(webhookUrl)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return nil, errors.New("invalid webhook")
+	}
+
+	return &SlackNotifyer{webhookUrl: webhookUrl, client: &http.Client{Timeout: 20 * time.Second}}, nil
+}
+
+func mrkdwn(text string) *slackText {
+	return &slackText{Type: "mrkdwn", Text: text}
+}
+
+// codeBlock shows text in a code block so the columns of size lists line up
+func codeBlock(title, text string) slackBlock {
+	return slackBlock{Type: "section", Text: mrkdwn("*" + slackEscaper.Replace(title) + "*\n```" + slackEscaper.Replace(text) + "```")}
+}
+
+// SetTemplate changes the template of the messages
+func (s *SlackNotifyer) SetTemplate(t *Template) {
+	s.template = t
+}
+
+// slackBlocks renders a message as Block Kit blocks
+func slackBlocks(message Message) slackMessage {
+	text := fmt.Sprintf("*<%s|%s>*", message.Url, slackEscaper.Replace(message.Title))
+	if message.Description != "" {
+		text += "\n" + slackEscaper.Replace(message.Description)
+	}
+
+	product := slackBlock{Type: "section", Text: mrkdwn(text)}
+
+	if message.Picture != "" {
+		product.Accessory = &slackImage{Type: "image", ImageUrl: message.Picture, AltText: message.Title}
+	}
+
+	var fieldBlocks []slackBlock
+	for _, field := range message.Fields {
+		if field.Inline {
+			product.Fields = append(product.Fields, *mrkdwn("*" + slackEscaper.Replace(field.Name) + "*\n" + slackEscaper.Replace(field.Value)))
+		} else {
+			fieldBlocks = append(fieldBlocks, codeBlock(field.Name, field.Value))
+		}
+	}
+
+	blocks := append([]slackBlock{product}, fieldBlocks...)
+
+	if len(message.Links) > 0 {
+		links := linkLines(message.Links, slackEscaper.Replace, func(link Link) string {
+			return fmt.Sprintf("<%s|%s>", link.Url, slackEscaper.Replace(link.Name))
+		}, slackTextLimit-len(message.LinksTitle)-3)
+		blocks = append(blocks, slackBlock{Type: "section", Text: mrkdwn("*" + slackEscaper.Replace(message.LinksTitle) + "*\n" + links)})
+	}
+
+	if message.Footer != "" {
+		footer, _ := json.Marshal(mrkdwn(slackEscaper.Replace(message.Footer)))
+		blocks = append(blocks, slackBlock{Type: "context", Elements: []json.RawMessage{footer}})
+	}
+
+	return slackMessage{Text: message.Title, Blocks: blocks}
+}
+
+func (s *SlackNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	if s == nil {
+		return errors.New("nil instance")
+	}
+
+	rendered, err := renderMessage(s.template, info)
+	if err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+
+	return s.NotifyMessage(rendered)
+}
+
+// NotifyMessage posts message as blocks to the webhook
+func (s *SlackNotifyer) NotifyMessage(rendered Message) error {
+	if s == nil {
+		return errors.New("nil instance")
+	}
+
+	body, err := json.Marshal(slackBlocks(rendered))
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.webhookUrl, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("slack: %w", StripUrl(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("slack: %w", statusError(resp.StatusCode, resp.Header, respBody))
+	}
+
+	return nil
+}
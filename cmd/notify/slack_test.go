@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSlackNotifyer(t *testing.T) {
+	_, err := NewSlackNotifyer("")
+	assert.EqualError(t, err, "empty webhook")
+
+	_, err = NewSlackNotifyer("hooks.slack.com/services/T/B/X")
+	assert.EqualError(t, err, "invalid webhook")
+
+	_, err = NewSlackNotifyer("https://hooks.slack.com/services/T000/B000/XXXX")
+	assert.NoError(t, err)
+}
+
+func TestSlackBlocks(t *testing.T) {
+	rendered, err := renderMessage(tamanhoTemplate, testRestock)
+	assert.NoError(t, err)
+	message := slackBlocks(rendered)
+
+	assert.Equal(t, "Shoe <Special> just restocked!", message.Text)
+	assert.Len(t, message.Blocks, 5, "product, available sizes, in stock sizes, quick links and footer")
+
+	product := message.Blocks[0]
+	assert.Equal(t, "*<https://www.nike.com.br/tenis/test.html|Shoe &lt;Special&gt; just restocked!>*", product.Text.Text)
+	assert.Equal(t, testRestock.Picture, product.Accessory.ImageUrl)
+	assert.Equal(t, "*Price*\nR$ 100,00", product.Fields[0].Text)
+	assert.Contains(t, message.Blocks[1].Text.Text, "Available sizes")
+	assert.Contains(t, message.Blocks[1].Text.Text, "42 - 111 - "+checkMark)
+	assert.Contains(t, message.Blocks[2].Text.Text, "In stock sizes")
+	assert.Equal(t, "*Quick links*\n42: <https://www.nike.com.br/tenis/test.html?tamanho=42|Product>", message.Blocks[3].Text.Text)
+
+	info := testRestock
+	info.Picture = ""
+	info.Sizes = nil
+	rendered, _ = renderMessage(nil, info)
+	message = slackBlocks(rendered)
+	assert.Nil(t, message.Blocks[0].Accessory)
+	assert.Len(t, message.Blocks, 2)
+}
+
+func TestSlackNotify(t *testing.T) {
+	t.Run("WithValidWebhook", func(t *testing.T) {
+		var received slackMessage
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+			w.Write([]byte("ok"))
+		}))
+		defer server.Close()
+
+		notifyer, _ := NewSlackNotifyer(server.URL)
+		assert.NoError(t, notifyer.Notify(testRestock))
+		assert.Equal(t, "Shoe <Special> just restocked!", received.Text)
+	})
+
+	t.Run("WithInvalidPayload", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid_blocks"))
+		}))
+		defer server.Close()
+
+		notifyer, _ := NewSlackNotifyer(server.URL)
+		err := notifyer.Notify(testRestock)
+		assert.EqualError(t, err, "slack: HTTP status 400: invalid_blocks")
+
+		var permanent *PermanentError
+		assert.ErrorAs(t, err, &permanent, "client errors shouldn't be retried")
+	})
+
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var notifyer *SlackNotifyer
+		assert.EqualError(t, notifyer.Notify(nkmonitor.RestockInfo{}), "nil instance")
+	})
+}
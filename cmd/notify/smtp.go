@@ -0,0 +1,272 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+)
+
+// SMTPSecurity defines how the connection to the SMTP server is secured
+type SMTPSecurity int
+
+const (
+	// SMTPStartTLS upgrades a plain connection with STARTTLS, failing if the server doesn't support it
+	SMTPStartTLS SMTPSecurity = iota
+	// SMTPImplicitTLS connects with TLS from the start, usually on port 465
+	SMTPImplicitTLS
+	// SMTPInsecure never uses TLS, only meant for local relays
+	SMTPInsecure
+)
+
+// ParseSMTPSecurity converts starttls, tls or none to a SMTPSecurity
+func ParseSMTPSecurity(name string) (SMTPSecurity, error) {
+	switch name {
+	case "starttls":
+		return SMTPStartTLS, nil
+	case "tls":
+		return SMTPImplicitTLS, nil
+	case "none":
+		return SMTPInsecure, nil
+	default:
+		return 0, fmt.Errorf("invalid smtp security %q", name)
+	}
+}
+
+// SMTPOptions configures a SMTPNotifyer
+type SMTPOptions struct {
+	Host      string
+	Port      int
+	Username  string // Username and Password are used for PLAIN auth, no auth is made if Username is empty
+	Password  string
+	From      string
+	To        []string
+	Security  SMTPSecurity
+	TLSConfig *tls.Config   // TLSConfig overrides the default TLS config, ServerName defaults to Host
+	Timeout   time.Duration // Timeout of the whole delivery, defaults to 30s
+}
+
+type SMTPNotifyer struct {
+	options  SMTPOptions
+	template *Template
+}
+
+var smtpHtmlTemplate = template.Must(template.New("restock").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: Arial, sans-serif;">
+<h2><a href="{{.Url}}">{{.Title}}</a></h2>
+{{if .Picture}}<img src="{{.Picture}}" alt="{{.Title}}" width="300">{{end}}
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+<p>{{range .Fields}}{{if .Inline}}<b>{{.Name}}:</b> {{.Value}}<br>{{end}}{{end}}</p>
+{{range .Fields}}{{if not .Inline}}<p><b>{{.Name}}</b></p>
+<pre>{{.Value}}</pre>
+{{end}}{{end}}{{if .Links}}<p><b>{{.LinksTitle}}</b><br>
+{{range .Links}}{{.Size}}:{{range $i, $link := .Links}}{{if $i}} ·{{end}} <a href="{{$link.Url}}">{{$link.Name}}</a>{{end}}<br>
+{{end}}</p>
+{{end}}{{if .Footer}}<p style="color: #888888;">{{.Footer}}</p>{{end}}
+</body>
+</html>
+`))
+
+// NewSMTPNotifyer creates a notifyer that emails restocks to every address in options.To
+func NewSMTPNotifyer(options SMTPOptions) (*SMTPNotifyer, error) {
+	if options.Host == "" {
+		return nil, errors.New("empty smtp host")
+	}
+
+	if options.Port <= 0 {
+		return nil, errors.New("invalid smtp port")
+	}
+
+	if options.From == "" || len(options.To) == 0 {
+		return nil, errors.New("smtp sender and recipients are required")
+	}
+
+	if options.Timeout <= 0 {
+		options.Timeout = 30 * time.Second
+	}
+
+	return &SMTPNotifyer{options: options}, nil
+}
+
+func (s *SMTPNotifyer) tlsConfig() *tls.Config {
+	if s.options.TLSConfig != nil {
+		config := s.options.TLSConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName = s.options.Host
+		}
+		return config
+	}
+	return &tls.Config{ServerName: s.options.Host}
+}
+
+// writePart writes a quoted-printable encoded part of the message
+func writePart(mw *multipart.Writer, contentType, content string) error {
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType + "; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// SetTemplate changes the template of the emails
+func (s *SMTPNotifyer) SetTemplate(t *Template) {
+	s.template = t
+}
+
+// message builds the multipart HTML and text email of a restock
+func (s *SMTPNotifyer) message(rendered Message) ([]byte, error) {
+	var (
+		buf  bytes.Buffer
+		body bytes.Buffer
+		mw   = multipart.NewWriter(&body)
+	)
+
+	if err := writePart(mw, "text/plain", plainText(rendered)); err != nil {
+		return nil, err
+	}
+
+	var html bytes.Buffer
+	if err := smtpHtmlTemplate.Execute(&html, rendered); err != nil {
+		return nil, err
+	}
+
+	if err := writePart(mw, "text/html", html.String()); err != nil {
+		return nil, err
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(&buf, "From: %s\r\n", s.options.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(s.options.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", rendered.Title))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	buf.Write(body.Bytes())
+
+	return buf.Bytes(), nil
+}
+
+// dial connects to the server, with TLS when using SMTPImplicitTLS
+func (s *SMTPNotifyer) dial() (*smtp.Client, error) {
+	var (
+		addr   = net.JoinHostPort(s.options.Host, strconv.Itoa(s.options.Port))
+		dialer = &net.Dialer{Timeout: s.options.Timeout}
+		conn   net.Conn
+		err    error
+	)
+
+	if s.options.Security == SMTPImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, s.tlsConfig())
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Bounds the whole conversation so a stuck server doesn't block forever
+	conn.SetDeadline(time.Now().Add(s.options.Timeout))
+
+	client, err := smtp.NewClient(conn, s.options.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (s *SMTPNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	if s == nil {
+		return errors.New("nil instance")
+	}
+
+	rendered, err := renderMessage(s.template, info)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+
+	return s.NotifyMessage(rendered)
+}
+
+// NotifyMessage emails message to every recipient
+func (s *SMTPNotifyer) NotifyMessage(rendered Message) error {
+	if s == nil {
+		return errors.New("nil instance")
+	}
+
+	message, err := s.message(rendered)
+	if err != nil {
+		return err
+	}
+
+	client, err := s.dial()
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer client.Close()
+
+	if s.options.Security == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("smtp: server does not support STARTTLS")
+		}
+		if err := client.StartTLS(s.tlsConfig()); err != nil {
+			return fmt.Errorf("smtp: %w", err)
+		}
+	}
+
+	if s.options.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.options.Username, s.options.Password, s.options.Host)); err != nil {
+			return fmt.Errorf("smtp: %w", err)
+		}
+	}
+
+	if err := client.Mail(s.options.From); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+
+	for _, to := range s.options.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp: %w", err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+
+	return client.Quit()
+}
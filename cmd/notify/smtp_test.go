@@ -0,0 +1,187 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTP is a minimal SMTP server that accepts a single message and records it
+type fakeSMTP struct {
+	listener   net.Listener
+	auth       string
+	from       string
+	recipients []string
+	data       string
+	done       chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	f := &fakeSMTP{listener: listener, done: make(chan struct{})}
+	t.Cleanup(func() { listener.Close() })
+
+	go f.serve()
+	return f
+}
+
+func (f *fakeSMTP) port() int {
+	return f.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (f *fakeSMTP) serve() {
+	defer close(f.done)
+
+	conn, err := f.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var (
+		r     = bufio.NewReader(conn)
+		reply = func(line string) { io.WriteString(conn, line+"\r\n") }
+	)
+
+	reply("220 localhost fake smtp")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch command {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			decoded, _ := base64.StdEncoding.DecodeString(strings.Fields(line)[2])
+			f.auth = string(decoded)
+			reply("235 authenticated")
+		case "MAIL":
+			f.from = line
+			reply("250 ok")
+		case "RCPT":
+			f.recipients = append(f.recipients, line)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var sb strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil || dataLine == ".\r\n" {
+					break
+				}
+				sb.WriteString(dataLine)
+			}
+			f.data = sb.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestNewSMTPNotifyer(t *testing.T) {
+	_, err := NewSMTPNotifyer(SMTPOptions{Port: 25, From: "a@b.c", To: []string{"d@e.f"}})
+	assert.Error(t, err, "empty host should be invalid")
+
+	_, err = NewSMTPNotifyer(SMTPOptions{Host: "localhost", From: "a@b.c", To: []string{"d@e.f"}})
+	assert.Error(t, err, "empty port should be invalid")
+
+	_, err = NewSMTPNotifyer(SMTPOptions{Host: "localhost", Port: 25, From: "a@b.c"})
+	assert.Error(t, err, "no recipients should be invalid")
+
+	security, err := ParseSMTPSecurity("tls")
+	assert.NoError(t, err)
+	assert.Equal(t, SMTPImplicitTLS, security)
+	_, err = ParseSMTPSecurity("ssl3")
+	assert.Error(t, err)
+}
+
+func TestSMTPNotify(t *testing.T) {
+	t.Run("WithLocalServer", func(t *testing.T) {
+		server := newFakeSMTP(t)
+		notifyer, err := NewSMTPNotifyer(SMTPOptions{
+			Host:     "localhost",
+			Port:     server.port(),
+			Username: "user",
+			Password: "pass",
+			From:     "monitor@example.com",
+			To:       []string{"a@example.com", "b@example.com"},
+			Security: SMTPInsecure,
+		})
+		assert.NoError(t, err)
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+		<-server.done
+
+		assert.Equal(t, "\x00user\x00pass", server.auth)
+		assert.Equal(t, "MAIL FROM:<monitor@example.com>", strings.SplitN(server.from, " BODY", 2)[0])
+		assert.Len(t, server.recipients, 2)
+
+		msg, err := mail.ReadMessage(strings.NewReader(server.data))
+		assert.NoError(t, err)
+
+		subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		assert.Equal(t, "Shoe <Special> just restocked!", subject)
+
+		mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+		assert.NoError(t, err)
+		assert.Equal(t, "multipart/alternative", mediaType)
+
+		parts := map[string]string{}
+		mr := multipart.NewReader(msg.Body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			// multipart.Reader transparently decodes quoted-printable parts
+			content, _ := io.ReadAll(part)
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			parts[partType] = string(content)
+		}
+
+		assert.Contains(t, parts["text/plain"], "42 - 111 - "+checkMark)
+		assert.Contains(t, parts["text/html"], "Shoe &lt;Special&gt; just restocked!", "html should be escaped")
+		assert.Contains(t, parts["text/html"], `<img src="https://example.com/picture.jpg"`)
+		assert.Contains(t, parts["text/html"], "<pre>43 - 222 - x</pre>")
+	})
+
+	t.Run("WithoutStartTLSSupport", func(t *testing.T) {
+		server := newFakeSMTP(t)
+		notifyer, _ := NewSMTPNotifyer(SMTPOptions{Host: "localhost", Port: server.port(), From: "a@b.c", To: []string{"d@e.f"}})
+		assert.EqualError(t, notifyer.Notify(testRestock), "smtp: server does not support STARTTLS")
+	})
+
+	t.Run("WithUnreachableServer", func(t *testing.T) {
+		listener, _ := net.Listen("tcp", "127.0.0.1:0")
+		port := listener.Addr().(*net.TCPAddr).Port
+		listener.Close()
+
+		notifyer, _ := NewSMTPNotifyer(SMTPOptions{Host: "127.0.0.1", Port: port, From: "a@b.c", To: []string{"d@e.f"}})
+		assert.Error(t, notifyer.Notify(testRestock))
+	})
+
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var notifyer *SMTPNotifyer
+		assert.EqualError(t, notifyer.Notify(nkmonitor.RestockInfo{}), "nil instance")
+	})
+}
@@ -0,0 +1,230 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+)
+
+const (
+	// DefaultTelegramApiUrl is the official Bot API base URL
+	DefaultTelegramApiUrl = "https://api.telegram.org"
+	// telegramCaptionLimit is the maximum length of a photo caption, longer messages are sent as text
+	telegramCaptionLimit = 1024
+	// telegramLinksLimit keeps the quick links from taking more than their share of the 4096 characters of a message
+	telegramLinksLimit = 2048
+)
+
+type TelegramNotifyer struct {
+	token    string
+	chatIDs  []string
+	apiUrl   string
+	client   *http.Client
+	template *Template
+}
+
+type telegramResponse struct {
+	Ok          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+	Result json.RawMessage `json:"result"`
+}
+
+// err returns the error of a failed call, marked for the queue like the errors of statusError
+func (r telegramResponse) err(method string) error {
+	err := fmt.Errorf("telegram %s: %s", method, r.Description)
+	switch {
+	case r.ErrorCode == http.StatusTooManyRequests:
+		return &RetryAfterError{Err: err, After: time.Duration(r.Parameters.RetryAfter) * time.Second}
+	case r.ErrorCode >= 400 && r.ErrorCode < 500:
+		// The chat doesn't exist, the bot was blocked or the message is invalid
+		return &PermanentError{Err: err}
+	default:
+		return err
+	}
+}
+
+// NewTelegramNotifyer creates a notifyer that sends restocks to every chat in chatIDs through the bot identified by token.
+// apiUrl is the Bot API base URL, the official one is used if it's empty.
+func NewTelegramNotifyer(token string, chatIDs []string, apiUrl string) (*TelegramNotifyer, error) {
+	if token == "" {
+		return nil, errors.New("empty telegram token")
+	}
+
+	if len(chatIDs) == 0 {
+		return nil, errors.New("no telegram chat ids")
+	}
+
+	if apiUrl == "" {
+		apiUrl = DefaultTelegramApiUrl
+	}
+
+	return &TelegramNotifyer{
+		token:   token,
+		chatIDs: chatIDs,
+		apiUrl:  strings.TrimSuffix(apiUrl, "/"),
+		client:  &http.Client{Timeout: 20 * time.Second},
+	}, nil
+}
+
+// SetTemplate changes the template of the messages
+func (t *TelegramNotifyer) SetTemplate(template *Template) {
+	t.template = template
+}
+
+// telegramMessage formats a message as Telegram HTML
+func telegramMessage(message Message) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "<b>%s</b>\n", html.EscapeString(message.Title))
+	if message.Description != "" {
+		fmt.Fprintf(&sb, "%s\n", html.EscapeString(message.Description))
+	}
+
+	for _, field := range message.Fields {
+		if field.Inline {
+			fmt.Fprintf(&sb, "%s: %s\n", html.EscapeString(field.Name), html.EscapeString(field.Value))
+		} else {
+			fmt.Fprintf(&sb, "\n<b>%s</b>\n%s\n", html.EscapeString(field.Name), html.EscapeString(field.Value))
+		}
+	}
+
+	if len(message.Links) > 0 {
+		fmt.Fprintf(&sb, "\n<b>%s</b>\n%s\n", html.EscapeString(message.LinksTitle), linkLines(message.Links, html.EscapeString, func(link Link) string {
+			return fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(link.Url), html.EscapeString(link.Name))
+		}, telegramLinksLimit))
+	}
+
+	if message.Footer != "" {
+		fmt.Fprintf(&sb, "\n<i>%s</i>\n", html.EscapeString(message.Footer))
+	}
+
+	fmt.Fprintf(&sb, "\n<a href=\"%s\">%s</a>", html.EscapeString(message.Url), html.EscapeString(message.LinkText))
+	return sb.String()
+}
+
+// TelegramCall calls a Bot API method of the bot identified by token with a JSON payload, decoding its result into result if it isn't nil.
+// Failed calls return errors marked for the queue, and the token is kept out of them
+func TelegramCall(ctx context.Context, client *http.Client, apiUrl, token, method string, payload map[string]interface{}, result interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiUrl+"/bot"+token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("telegram %s: %w", method, StripUrl(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram %s: %w", method, StripUrl(err))
+	}
+	defer resp.Body.Close()
+
+	var parsed telegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return fmt.Errorf("telegram %s: HTTP status %v with invalid body", method, resp.StatusCode)
+	}
+
+	if !parsed.Ok {
+		return parsed.err(method)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(parsed.Result, result); err != nil {
+			return fmt.Errorf("telegram %s: %w", method, err)
+		}
+	}
+
+	return nil
+}
+
+// call calls a Bot API method with a JSON payload
+func (t *TelegramNotifyer) call(method string, payload map[string]interface{}) error {
+	return TelegramCall(context.Background(), t.client, t.apiUrl, t.token, method, payload, nil)
+}
+
+func (t *TelegramNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	if t == nil {
+		return errors.New("nil instance")
+	}
+
+	rendered, err := renderMessage(t.template, info)
+	if err != nil {
+		return fmt.Errorf("telegram: %w", err)
+	}
+
+	return t.NotifyMessage(rendered)
+}
+
+// NotifyMessage sends message to every chat, as the caption of its picture when it fits.
+// A failure in any chat fails the whole message, so retrying it sends it again to the chats that got it, see PerChat
+func (t *TelegramNotifyer) NotifyMessage(rendered Message) error {
+	if t == nil {
+		return errors.New("nil instance")
+	}
+
+	var (
+		message = telegramMessage(rendered)
+		failed  []string
+	)
+
+	for _, chatID := range t.chatIDs {
+		var err error
+		if rendered.Picture != "" && len(message) <= telegramCaptionLimit {
+			err = t.call("sendPhoto", map[string]interface{}{
+				"chat_id":    chatID,
+				"photo":      rendered.Picture,
+				"caption":    message,
+				"parse_mode": "HTML",
+			})
+		} else {
+			err = t.call("sendMessage", map[string]interface{}{
+				"chat_id":    chatID,
+				"text":       message,
+				"parse_mode": "HTML",
+			})
+		}
+		if err != nil && len(t.chatIDs) == 1 {
+			return err
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("chat %s: %v", chatID, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// PerChat splits the notifyer into one for each chat, with the same bot and template, so every chat can be retried on its own
+func (t *TelegramNotifyer) PerChat() []*TelegramNotifyer {
+	notifyers := make([]*TelegramNotifyer, len(t.chatIDs))
+	for i, chatID := range t.chatIDs {
+		chat := *t
+		chat.chatIDs = []string{chatID}
+		notifyers[i] = &chat
+	}
+	return notifyers
+}
+
+// ChatIDs returns the chats the notifyer sends to
+func (t *TelegramNotifyer) ChatIDs() []string {
+	return t.chatIDs
+}
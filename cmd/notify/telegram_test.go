@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/stretchr/testify/assert"
+)
+
+var testRestock = nkmonitor.RestockInfo{
+	Name:    "Shoe <Special>",
+	Path:    "/tenis/test.html",
+	Code:    "ABC123",
+	Price:   "R$ 100,00",
+	Picture: "https://example.com/picture.jpg",
+	Sizes: []*nkmonitor.SizeInfo{
+		{Description: "42", Sku: "111", HasStock: true, IsAvailable: true, Restocked: true},
+		{Description: "43", Sku: "222", HasStock: true},
+	},
+}
+
+// fakeTelegram answers like the Bot API, failing every request when ok is false
+func fakeTelegram(t *testing.T, ok bool) *recordingServer {
+	if ok {
+		return newRecordingServer(t, respondWith(http.StatusOK, `{"ok":true,"result":{}}`))
+	}
+	return newRecordingServer(t, respondWith(http.StatusBadRequest, `{"ok":false,"description":"Bad Request: chat not found"}`))
+}
+
+func TestNewTelegramNotifyer(t *testing.T) {
+	_, err := NewTelegramNotifyer("", []string{"1"}, "")
+	assert.Error(t, err, "empty token should be invalid")
+
+	_, err = NewTelegramNotifyer("token", nil, "")
+	assert.Error(t, err, "no chats should be invalid")
+
+	notifyer, err := NewTelegramNotifyer("token", []string{"1"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultTelegramApiUrl, notifyer.apiUrl)
+}
+
+func TestTelegramNotify(t *testing.T) {
+	t.Run("WithPicture", func(t *testing.T) {
+		server := fakeTelegram(t, true)
+		notifyer, _ := NewTelegramNotifyer("123:abc", []string{"1", "@channel"}, server.URL+"/")
+
+		assert.NoError(t, notifyer.Notify(testRestock))
+		assert.Equal(t, []string{"/bot123:abc/sendPhoto", "/bot123:abc/sendPhoto"}, server.paths())
+		assert.Equal(t, "@channel", server.all()[1].Body["chat_id"])
+		assert.Equal(t, testRestock.Picture, server.all()[0].Body["photo"])
+
+		caption := server.all()[0].Body["caption"].(string)
+		assert.Contains(t, caption, "Shoe &lt;Special&gt; just restocked!", "name should be escaped")
+		assert.Contains(t, caption, "42 - 111 - "+checkMark)
+		assert.Contains(t, caption, "In stock sizes")
+		assert.Contains(t, caption, "https://www.nike.com.br/tenis/test.html")
+	})
+
+	t.Run("WithoutPicture", func(t *testing.T) {
+		server := fakeTelegram(t, true)
+		notifyer, _ := NewTelegramNotifyer("123:abc", []string{"1"}, server.URL)
+
+		info := testRestock
+		info.Picture = ""
+		assert.NoError(t, notifyer.Notify(info))
+		assert.Equal(t, []string{"/bot123:abc/sendMessage"}, server.paths())
+		assert.Equal(t, "HTML", server.all()[0].Body["parse_mode"])
+	})
+
+	t.Run("WithApiError", func(t *testing.T) {
+		server := fakeTelegram(t, false)
+		notifyer, _ := NewTelegramNotifyer("123:abc", []string{"1"}, server.URL)
+
+		err := notifyer.Notify(testRestock)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "chat not found")
+	})
+
+	t.Run("WithErrorCodes", func(t *testing.T) {
+		server := newRecordingServer(t, func(request recordedRequest) (int, string) {
+			if request.Body["chat_id"] == "blocked" {
+				return http.StatusForbidden, `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`
+			}
+			return http.StatusTooManyRequests, `{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":5}}`
+		})
+
+		var permanent *PermanentError
+		notifyer, _ := NewTelegramNotifyer("123:abc", []string{"blocked"}, server.URL)
+		assert.ErrorAs(t, notifyer.Notify(testRestock), &permanent, "client errors shouldn't be retried")
+
+		var retryAfter *RetryAfterError
+		notifyer, _ = NewTelegramNotifyer("123:abc", []string{"1"}, server.URL)
+		assert.ErrorAs(t, notifyer.Notify(testRestock), &retryAfter)
+		assert.Equal(t, 5*time.Second, retryAfter.After)
+	})
+
+	t.Run("WithUnreachableApi", func(t *testing.T) {
+		notifyer, _ := NewTelegramNotifyer("123:secret", []string{"1"}, "http://127.0.0.1:1")
+
+		err := notifyer.Notify(testRestock)
+		assert.Error(t, err)
+		assert.False(t, strings.Contains(err.Error(), "secret"), "errors should not leak the token")
+	})
+
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var notifyer *TelegramNotifyer
+		assert.EqualError(t, notifyer.Notify(nkmonitor.RestockInfo{}), "nil instance")
+	})
+}
+
+func TestTelegramPerChat(t *testing.T) {
+	server := fakeTelegram(t, true)
+	notifyer, _ := NewTelegramNotifyer("123:abc", []string{"1", "@channel"}, server.URL)
+
+	chats := notifyer.PerChat()
+	assert.Len(t, chats, 2)
+	assert.Equal(t, []string{"@channel"}, chats[1].ChatIDs())
+	assert.Equal(t, []string{"1", "@channel"}, notifyer.ChatIDs(), "the notifyer split should be left as is")
+
+	assert.NoError(t, chats[1].Notify(testRestock))
+	assert.Len(t, server.all(), 1)
+	assert.Equal(t, "@channel", server.last().Body["chat_id"])
+}
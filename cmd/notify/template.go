@@ -0,0 +1,325 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/rodjunger/nkmonitor"
+)
+
+// MessageTemplate customizes the messages of the notifyers read by people (Discord, Telegram, Slack, email, ntfy and Gotify).
+// Title, Description, Footer and the fields are text/template templates executed with a TemplateData, the Links also go in the Events of the other notifyers
+type MessageTemplate struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Fields      []TemplateField   `json:"fields"` // Fields that render to an empty value are left out
+	Footer      string            `json:"footer"`
+	Links       []LinkTemplate    `json:"links"`     // Links are the quick links of every size that can be added to cart
+	SizeParam   string            `json:"sizeParam"` // SizeParam is the query parameter that selects a size in {{.SizeUrl}}, like tamanho, {{.SizeUrl}} is empty without it
+	Colors      map[string]string `json:"colors"`    // Colors maps event kinds to #rrggbb colors
+	Locale      string            `json:"locale"`    // Locale of the labels given by the t function: en or pt-BR
+}
+
+type TemplateField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"` // Inline fields are short and shown side by side when the destination supports it
+}
+
+// TemplateData is what templates are executed with, the fields of the restock can be used directly, like {{.Name}}
+type TemplateData struct {
+	nkmonitor.RestockInfo
+	Kind           string
+	Url            string
+	AvailableSizes []string // AvailableSizes are the sizes that can be added to cart, formatted as size - SKU - restocked
+	InStockSizes   []string // InStockSizes are the sizes that are only in stock, formatted as size - SKU - restocked
+}
+
+// DefaultMessageTemplate is the layout used when no template is given
+var DefaultMessageTemplate = MessageTemplate{
+	Title: `{{.Name}} {{t "just restocked!"}}`,
+	Fields: []TemplateField{
+		{Name: `{{t "Price"}}`, Value: `{{.Price}}`, Inline: true},
+		{Name: `{{t "Code"}}`, Value: `{{.Code}}`, Inline: true},
+		{Name: `{{t "Available sizes (size - SKU - restocked)"}}`, Value: `{{join .AvailableSizes "\n"}}`},
+		{Name: `{{t "In stock sizes (size - SKU - restocked)"}}`, Value: `{{join .InStockSizes "\n"}}`},
+	},
+	Footer: "Powered by the openMonitors project",
+	Links:  []LinkTemplate{{Name: `{{t "Product"}}`, Url: `{{.SizeUrl}}`}},
+	Colors: map[string]string{KindRestock: "#00ff00"},
+	Locale: "en",
+}
+
+// translations of the labels given by the t function, en is the key itself
+var translations = map[string]map[string]string{
+	"en": {},
+	"pt-BR": {
+		"just restocked!": "voltou ao estoque!",
+		"Price":           "Preço",
+		"Code":            "Código",
+		"Available sizes (size - SKU - restocked)": "Tamanhos disponíveis (tamanho - SKU - reposto)",
+		"In stock sizes (size - SKU - restocked)":  "Tamanhos em estoque (tamanho - SKU - reposto)",
+		"Open product":    "Abrir produto",
+		"Quick links":     "Links rápidos",
+		"Product":         "Produto",
+		"Last updated":    "Atualizado",
+		"Restock digest":  "Resumo de reposições",
+		"Restocked sizes": "Tamanhos repostos",
+		"%d restocks of %d products from %s to %s": "%d reposições de %d produtos das %s às %s",
+		"%d more products not listed":              "Mais %d produtos não listados",
+	},
+}
+
+// Message is a rendered template, each notifyer lays it out in its own format
+type Message struct {
+	Title       string
+	Description string
+	Fields      []MessageField
+	Footer      string
+	Color       int
+	Url         string
+	Picture     string
+	LinkText    string // LinkText is the translated label of links to the product
+	Links       []SizeLinks
+	LinksTitle  string // LinksTitle is the translated heading of the links
+	Available   bool   // Available is set when a size can be added to cart, push notifyers raise the priority of these
+}
+
+type MessageField struct {
+	Name   string
+	Value  string
+	Inline bool
+}
+
+type compiledField struct {
+	name   *template.Template
+	value  *template.Template
+	inline bool
+}
+
+// Template is a parsed MessageTemplate
+type Template struct {
+	title       *template.Template
+	description *template.Template
+	footer      *template.Template
+	fields      []compiledField
+	links       []compiledLink
+	sizeParam   string
+	colors      map[string]int
+	labels      map[string]string
+}
+
+// defaultTemplate is used by notifyers without a template
+var defaultTemplate = MustTemplate(DefaultMessageTemplate)
+
+// NewTemplate parses every template of source
+func NewTemplate(source MessageTemplate) (*Template, error) {
+	if source.Locale == "" {
+		source.Locale = "en"
+	}
+
+	labels, ok := translations[source.Locale]
+	if !ok {
+		return nil, fmt.Errorf("unknown locale %q", source.Locale)
+	}
+
+	t := &Template{sizeParam: source.SizeParam, colors: map[string]int{}, labels: labels}
+
+	funcs := template.FuncMap{
+		"t":     t.translate,
+		"join":  strings.Join,
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"query": url.QueryEscape,
+	}
+
+	parse := func(name, text string) (*template.Template, error) {
+		parsed, err := template.New(name).Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s template: %w", name, err)
+		}
+		return parsed, nil
+	}
+
+	var err error
+	if t.title, err = parse("title", source.Title); err != nil {
+		return nil, err
+	}
+	if t.description, err = parse("description", source.Description); err != nil {
+		return nil, err
+	}
+	if t.footer, err = parse("footer", source.Footer); err != nil {
+		return nil, err
+	}
+
+	for i, field := range source.Fields {
+		name, err := parse(fmt.Sprintf("field %d name", i), field.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := parse(fmt.Sprintf("field %d value", i), field.Value)
+		if err != nil {
+			return nil, err
+		}
+		t.fields = append(t.fields, compiledField{name: name, value: value, inline: field.Inline})
+	}
+
+	for i, link := range source.Links {
+		name, err := parse(fmt.Sprintf("link %d name", i), link.Name)
+		if err != nil {
+			return nil, err
+		}
+		linkUrl, err := parse(fmt.Sprintf("link %d url", i), link.Url)
+		if err != nil {
+			return nil, err
+		}
+		t.links = append(t.links, compiledLink{name: name, url: linkUrl})
+	}
+
+	for kind, color := range source.Colors {
+		parsed, err := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 24)
+		if err != nil {
+			return nil, fmt.Errorf("invalid color %q for %s", color, kind)
+		}
+		t.colors[kind] = int(parsed)
+	}
+
+	return t, nil
+}
+
+// MustTemplate is like NewTemplate but panics if source is invalid
+func MustTemplate(source MessageTemplate) *Template {
+	t, err := NewTemplate(source)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// clone returns a copy of m that shares no slices or maps with it
+func (m MessageTemplate) clone() MessageTemplate {
+	m.Fields = append([]TemplateField(nil), m.Fields...)
+	m.Links = append([]LinkTemplate(nil), m.Links...)
+	if m.Colors != nil {
+		colors := make(map[string]string, len(m.Colors))
+		for kind, color := range m.Colors {
+			colors[kind] = color
+		}
+		m.Colors = colors
+	}
+	return m
+}
+
+// LoadTemplate reads a JSON MessageTemplate file, keys missing from the file keep the value of DefaultMessageTemplate
+func LoadTemplate(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The file is decoded over a copy, decoding reuses the slices and maps of the default otherwise
+	source := DefaultMessageTemplate.clone()
+	if err := json.Unmarshal(data, &source); err != nil {
+		return nil, fmt.Errorf("invalid template file %s: %w", path, err)
+	}
+
+	return NewTemplate(source)
+}
+
+func (t *Template) translate(label string) string {
+	if translated, ok := t.labels[label]; ok {
+		return translated
+	}
+	return label
+}
+
+func execute(tmpl *template.Template, data TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// newTemplateData returns what templates are executed with for the event of kind created from info
+func newTemplateData(info nkmonitor.RestockInfo, kind string) TemplateData {
+	availableSizes, inStockSizes := splitSizes(info)
+	return TemplateData{
+		RestockInfo:    info,
+		Kind:           kind,
+		Url:            productUrl(info),
+		AvailableSizes: availableSizes,
+		InStockSizes:   inStockSizes,
+	}
+}
+
+// Render executes the template for the event of kind created from info
+func (t *Template) Render(info nkmonitor.RestockInfo, kind string) (Message, error) {
+	data := newTemplateData(info, kind)
+
+	message := Message{
+		Color:      t.colors[kind],
+		Url:        data.Url,
+		Picture:    info.Picture,
+		LinkText:   t.translate("Open product"),
+		LinksTitle: t.translate("Quick links"),
+		Available:  len(data.AvailableSizes) > 0,
+	}
+
+	var err error
+	if message.Links, err = renderLinks(t.links, t.sizeParam, data); err != nil {
+		return Message{}, err
+	}
+	if message.Title, err = execute(t.title, data); err != nil {
+		return Message{}, err
+	}
+	if message.Description, err = execute(t.description, data); err != nil {
+		return Message{}, err
+	}
+	if message.Footer, err = execute(t.footer, data); err != nil {
+		return Message{}, err
+	}
+
+	for _, field := range t.fields {
+		value, err := execute(field.value, data)
+		if err != nil {
+			return Message{}, err
+		}
+		if value == "" {
+			continue
+		}
+
+		name, err := execute(field.name, data)
+		if err != nil {
+			return Message{}, err
+		}
+		message.Fields = append(message.Fields, MessageField{Name: name, Value: value, Inline: field.inline})
+	}
+
+	return message, nil
+}
+
+// renderMessage renders info with t, or the default template if t is nil
+func renderMessage(t *Template, info nkmonitor.RestockInfo) (Message, error) {
+	if t == nil {
+		t = defaultTemplate
+	}
+	return t.Render(info, KindRestock)
+}
+
+// MessageNotifyer is implemented by the notifyers that can send messages not created from a single restock, like digests
+type MessageNotifyer interface {
+	Notifyer
+	NotifyMessage(message Message) error
+}
+
+// Templater is implemented by the notifyers whose messages can be customized with a Template
+type Templater interface {
+	SetTemplate(t *Template)
+}
@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultTemplate(t *testing.T) {
+	message, err := renderMessage(nil, testRestock)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Shoe <Special> just restocked!", message.Title)
+	assert.Equal(t, 65280, message.Color)
+	assert.Equal(t, "Powered by the openMonitors project", message.Footer)
+	assert.Equal(t, []MessageField{
+		{Name: "Price", Value: "R$ 100,00", Inline: true},
+		{Name: "Code", Value: "ABC123", Inline: true},
+		{Name: "Available sizes (size - SKU - restocked)", Value: "42 - 111 - " + checkMark},
+		{Name: "In stock sizes (size - SKU - restocked)", Value: "43 - 222 - x"},
+	}, message.Fields)
+	assert.Empty(t, message.Links, "sizes should only have links once a parameter selects them")
+
+	assert.Equal(t, "Shoe <Special> just restocked!\nhttps://www.nike.com.br/tenis/test.html\n\n"+
+		"Price: R$ 100,00\nCode: ABC123\n\n"+
+		"Available sizes (size - SKU - restocked)\n42 - 111 - "+checkMark+"\n\n"+
+		"In stock sizes (size - SKU - restocked)\n43 - 222 - x\n\n"+
+		"Powered by the openMonitors project\n", plainText(message))
+}
+
+func TestNewTemplate(t *testing.T) {
+	t.Run("WithCustomLayout", func(t *testing.T) {
+		template, err := NewTemplate(MessageTemplate{
+			Title:       `{{upper .Code}} is back`,
+			Description: `{{if .InStockSizes}}Only in stock: {{join .InStockSizes ", "}}{{end}}`,
+			Fields: []TemplateField{
+				{Name: "Nickname", Value: "{{.NickName}}"},
+				{Name: `{{t "Price"}}`, Value: "{{.Price}}", Inline: true},
+			},
+			Colors: map[string]string{KindRestock: "#FF8800"},
+			Locale: "pt-BR",
+		})
+		assert.NoError(t, err)
+
+		message, err := template.Render(testRestock, KindRestock)
+		assert.NoError(t, err)
+		assert.Equal(t, "ABC123 is back", message.Title)
+		assert.Equal(t, "Only in stock: 43 - 222 - x", message.Description)
+		assert.Equal(t, []MessageField{{Name: "Preço", Value: "R$ 100,00", Inline: true}}, message.Fields, "empty fields should be left out")
+		assert.Equal(t, 0xff8800, message.Color)
+		assert.Empty(t, message.Footer)
+		assert.Equal(t, "Abrir produto", message.LinkText)
+	})
+
+	t.Run("WithInvalidTemplate", func(t *testing.T) {
+		_, err := NewTemplate(MessageTemplate{Title: "{{.Name"})
+		assert.ErrorContains(t, err, "invalid title template")
+	})
+
+	t.Run("WithUnknownLocale", func(t *testing.T) {
+		_, err := NewTemplate(MessageTemplate{Locale: "fr"})
+		assert.EqualError(t, err, `unknown locale "fr"`)
+	})
+
+	t.Run("WithInvalidColor", func(t *testing.T) {
+		_, err := NewTemplate(MessageTemplate{Colors: map[string]string{KindRestock: "green"}})
+		assert.EqualError(t, err, `invalid color "green" for restock`)
+	})
+
+	t.Run("WithUnknownField", func(t *testing.T) {
+		template, err := NewTemplate(MessageTemplate{Title: "{{.Stock}}"})
+		assert.NoError(t, err)
+
+		_, err = template.Render(testRestock, KindRestock)
+		assert.Error(t, err, "unknown fields should fail when rendering")
+	})
+}
+
+func TestLoadTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "template.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"footer": "Acme Alerts", "locale": "pt-BR"}`), 0o600))
+
+	template, err := LoadTemplate(path)
+	assert.NoError(t, err)
+
+	message, err := template.Render(testRestock, KindRestock)
+	assert.NoError(t, err)
+	assert.Equal(t, "Shoe <Special> voltou ao estoque!", message.Title, "keys missing from the file should keep the default")
+	assert.Equal(t, "Acme Alerts", message.Footer)
+	assert.Len(t, message.Fields, 4)
+
+	_, err = LoadTemplate(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestLoadTemplateKeepsDefault(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		first   = filepath.Join(dir, "first.json")
+		second  = filepath.Join(dir, "second.json")
+		initial = DefaultMessageTemplate.clone()
+	)
+	assert.NoError(t, os.WriteFile(first, []byte(`{"fields": [{"name": "Stock", "value": "{{.Price}}"}], "links": [{"name": "Task", "url": "https://tool.example"}], "colors": {"restock": "#ff0000"}}`), 0o600))
+	assert.NoError(t, os.WriteFile(second, []byte(`{"footer": "Acme Alerts", "sizeParam": "tamanho"}`), 0o600))
+
+	_, err := LoadTemplate(first)
+	assert.NoError(t, err)
+	assert.Equal(t, initial, DefaultMessageTemplate, "loading a file should not change the default")
+
+	template, err := LoadTemplate(second)
+	assert.NoError(t, err)
+	message, err := template.Render(testRestock, KindRestock)
+	assert.NoError(t, err)
+	assert.Len(t, message.Fields, 4, "a file should not get the fields of the one loaded before it")
+	assert.Equal(t, []Link{{Name: "Product", Url: "https://www.nike.com.br/tenis/test.html?tamanho=42"}}, message.Links[0].Links, "a file should not get the links of the one loaded before it")
+}
+
+func TestPreview(t *testing.T) {
+	for _, format := range PreviewFormats {
+		t.Run(format, func(t *testing.T) {
+			preview, err := Preview(format, nil, SampleRestock)
+			assert.NoError(t, err)
+			assert.Contains(t, preview, "DD1391-100")
+		})
+	}
+
+	_, err := Preview("fax", nil, SampleRestock)
+	assert.EqualError(t, err, `unknown preview format "fax"`)
+}
@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+)
+
+const (
+	defaultWebhookTimeout = 10 * time.Second
+	// SignatureHeader holds the hex encoded HMAC-SHA256 of the body, keyed with the shared secret
+	SignatureHeader = "X-Signature"
+)
+
+// WebhookOptions configures a WebhookNotifyer, zero values use the defaults
+type WebhookOptions struct {
+	Headers map[string]string // Headers are added to every request
+	Secret  string            // Secret signs the body in the X-Signature header, requests are not signed if it's empty
+	Timeout time.Duration     // Timeout of every request, defaults to 10s
+}
+
+// WebhookNotifyer POSTs every restock as a JSON Event to an URL
+type WebhookNotifyer struct {
+	url      string
+	options  WebhookOptions
+	client   *http.Client
+	template *Template
+}
+
+// NewWebhookNotifyer creates a notifyer that POSTs restocks as JSON Events to webhookUrl
+func NewWebhookNotifyer(webhookUrl string, options WebhookOptions) (*WebhookNotifyer, error) {
+	if webhookUrl == "" {
+		return nil, errors.New("empty webhook")
+	}
+
+	parsed, err := url.Parse(webhookUrl)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return nil, errors.New("invalid webhook")
+	}
+
+	if options.Timeout <= 0 {
+		options.Timeout = defaultWebhookTimeout
+	}
+
+	return &WebhookNotifyer{url: webhookUrl, options: options, client: &http.Client{Timeout: options.Timeout}}, nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body, as sent in the X-Signature header
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SetTemplate changes the template whose quick links are added to the events
+func (w *WebhookNotifyer) SetTemplate(t *Template) {
+	w.template = t
+}
+
+func (w *WebhookNotifyer) Notify(info nkmonitor.RestockInfo) error {
+	if w == nil {
+		return errors.New("nil instance")
+	}
+
+	event, err := newEvent(w.template, info)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.options.Headers {
+		req.Header.Set(key, value)
+	}
+	if w.options.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.options.Secret, body))
+	}
+
+	// Failures are retried by the queue in front of the notifyer
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", StripUrl(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook: %w", statusError(resp.StatusCode, resp.Header, respBody))
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestNewWebhookNotifyer(t *testing.T) {
+	_, err := NewWebhookNotifyer("", WebhookOptions{})
+	assert.EqualError(t, err, "empty webhook")
+
+	_, err = NewWebhookNotifyer("ftp://example.com", WebhookOptions{})
+	assert.EqualError(t, err, "invalid webhook")
+
+	notifyer, err := NewWebhookNotifyer("https://example.com/hook", WebhookOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultWebhookTimeout, notifyer.client.Timeout)
+}
+
+func TestWebhookNotify(t *testing.T) {
+	t.Run("WithSignature", func(t *testing.T) {
+		var (
+			received Event
+			headers  http.Header
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			headers = r.Header
+			assert.Equal(t, Sign("secret", body), r.Header.Get(SignatureHeader), "signature should match the body")
+			assert.NoError(t, json.Unmarshal(body, &received))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		notifyer, _ := NewWebhookNotifyer(server.URL, WebhookOptions{Secret: "secret", Headers: map[string]string{"Authorization": "Bearer abc"}})
+		notifyer.SetTemplate(tamanhoTemplate)
+
+		info := testRestock
+		info.TaskID = "task-id"
+		info.DetectedAt = time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC)
+		assert.NoError(t, notifyer.Notify(info))
+
+		assert.Equal(t, "Bearer abc", headers.Get("Authorization"))
+		assert.Equal(t, EventVersion, received.Version)
+		assert.Equal(t, KindRestock, received.Kind)
+		assert.Equal(t, "task-id", received.TaskID)
+		assert.True(t, info.DetectedAt.Equal(received.DetectedAt))
+		assert.False(t, received.SentAt.IsZero())
+		assert.Equal(t, "https://www.nike.com.br/tenis/test.html", received.Product.Url)
+		assert.Len(t, received.Product.Sizes, 2)
+		assert.True(t, received.Product.Sizes[0].Restocked)
+		assert.Equal(t, []Link{{Name: "Product", Url: "https://www.nike.com.br/tenis/test.html?tamanho=42"}}, received.Product.Sizes[0].Links)
+	})
+
+	t.Run("WithServerError", func(t *testing.T) {
+		calls := atomic.NewInt32(0)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Inc()
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		notifyer, _ := NewWebhookNotifyer(server.URL, WebhookOptions{})
+		err := notifyer.Notify(testRestock)
+		assert.EqualError(t, err, "webhook: HTTP status 502: ")
+		assert.False(t, errors.As(err, new(*PermanentError)), "server errors should be retried")
+		assert.Equal(t, int32(1), calls.Load(), "retries are left to the queue")
+	})
+
+	t.Run("WithClientError", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("bad signature"))
+		}))
+		defer server.Close()
+
+		notifyer, _ := NewWebhookNotifyer(server.URL, WebhookOptions{})
+		err := notifyer.Notify(testRestock)
+		assert.EqualError(t, err, "webhook: HTTP status 401: bad signature")
+
+		var permanent *PermanentError
+		assert.ErrorAs(t, err, &permanent, "client errors shouldn't be retried")
+	})
+
+	t.Run("WithRateLimit", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		notifyer, _ := NewWebhookNotifyer(server.URL, WebhookOptions{})
+		var retryAfter *RetryAfterError
+		assert.ErrorAs(t, notifyer.Notify(testRestock), &retryAfter)
+		assert.Equal(t, 7*time.Second, retryAfter.After)
+	})
+
+	t.Run("WithNilInstance", func(t *testing.T) {
+		var notifyer *WebhookNotifyer
+		assert.EqualError(t, notifyer.Notify(nkmonitor.RestockInfo{}), "nil instance")
+	})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mileusna/useragent"
+	"github.com/rodjunger/nkmonitor"
+	"github.com/rodjunger/nkmonitor/cmd/notify"
+	"github.com/saucesteals/mimic"
+	"github.com/spf13/cobra"
+)
+
+var (
+	previewFormat string
+	notifyTestUrl string
+)
+
+// notifyCmd groups the commands that help setting up notifications
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "notification tools",
+}
+
+var previewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "renders a sample restock with the notification template",
+	Long:  "renders a sample restock with the template given by --template, or the default one, in the format of a notification destination.",
+	RunE:  previewNotification,
+}
+
+func previewNotification(cmd *cobra.Command, args []string) error {
+	var (
+		template *notify.Template
+		err      error
+	)
+
+	if cfg.templateFile != "" {
+		if template, err = notify.LoadTemplate(cfg.templateFile); err != nil {
+			return err
+		}
+	}
+
+	preview, err := notify.Preview(previewFormat, template, notify.SampleRestock)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), preview)
+	return nil
+}
+
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "sends a test restock through every configured notifier",
+	Long: "sends a sample restock, or the current state of the product given by --url, through every notifier given by flag or in the config file, ignoring their rules. " +
+		"Reports whether each notifier succeeded, how long it took and why it failed. Notifiers with digests get the restock right away instead of in a digest, " +
+		"and Discord gets it in a message of its own instead of waiting to batch it with others.",
+	RunE: testNotifications,
+}
+
+// notifyResult is how sending the test restock through a notifier went
+type notifyResult struct {
+	latency time.Duration
+	err     error
+}
+
+func testNotifications(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	info := notify.SampleRestock
+	if notifyTestUrl != "" {
+		proxies := cfg.proxies
+		if cfg.proxyFile != "" {
+			fileProxies, err := readProxyFile(cfg.proxyFile)
+			if err != nil {
+				return err
+			}
+			proxies = append(append([]string{}, proxies...), fileProxies...)
+		}
+
+		m, _ := mimic.Chromium(mimic.BrandChrome, useragent.Parse(cfg.userAgent).Version)
+		monitor, err := nkmonitor.NewMonitor(cfg.userAgent, cfg.delay, proxies, m)
+		if err != nil {
+			return err
+		}
+
+		if info, err = monitor.FetchProduct(notifyTestUrl); err != nil {
+			return err
+		}
+
+		// The sizes that can be added to cart are flagged as restocked so the notifications look like real ones
+		for _, size := range info.Sizes {
+			size.Restocked = size.IsAvailable
+		}
+	}
+	info.TaskID = "test"
+	info.DetectedAt = time.Now()
+
+	if cfg.configFile != "" {
+		fileCfg, err := loadConfigFile(cfg.configFile)
+		if err != nil {
+			return err
+		}
+		cfg.notifiers = fileCfg.Notifiers
+	}
+
+	routes, err := buildNotifyers(cfg.notifiers)
+	if err != nil {
+		return err
+	}
+
+	if len(routes) == 0 {
+		return errors.New("no notifiers configured")
+	}
+
+	var (
+		results = make([]notifyResult, len(routes))
+		wg      sync.WaitGroup
+	)
+	for i, route := range routes {
+		// Digests would only send the restock when closed, after the latency is measured and without a result
+		notifyer := route.Notifyer
+		if digest, ok := notifyer.(*notify.DigestNotifyer); ok {
+			notifyer = digest.Unwrap()
+		}
+
+		wg.Add(1)
+		go func(i int, notifyer notify.Notifyer) {
+			defer wg.Done()
+			start := time.Now()
+			var err error
+			if discord, ok := notifyer.(*notify.DiscordNotifyer); ok {
+				// Batching would add its window to the latency
+				err = discord.NotifyNow(info)
+			} else {
+				err = notifyer.Notify(info)
+			}
+			results[i] = notifyResult{latency: time.Since(start), err: err}
+		}(i, notifyer)
+	}
+	wg.Wait()
+
+	closeNotifyers(routes)
+
+	failed := 0
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NOTIFIER\tRESULT\tLATENCY\tERROR")
+	for i, route := range routes {
+		result, errText := "ok", ""
+		if results[i].err != nil {
+			failed++
+			result, errText = "failed", results[i].err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", route.Name, result, results[i].latency.Round(time.Millisecond), errText)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d notifiers failed", failed, len(routes))
+	}
+
+	return nil
+}
+
+func init() {
+	previewCmd.Flags().StringVarP(&previewFormat, "format", "f", "text", "format of the preview: "+strings.Join(notify.PreviewFormats, ", "))
+	notifyCmd.AddCommand(previewCmd)
+	testCmd.Flags().StringVar(&notifyTestUrl, "url", "", "url of a product whose current state is sent instead of the sample restock")
+	notifyCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(notifyCmd)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rodjunger/nkmonitor/cmd/notify"
+	"github.com/rs/zerolog/log"
+)
+
+// buildNotifyers creates a route for every destination given by flag, getting every restock, and for every notifier of the config file
+func buildNotifyers(configured []notifierConfig) ([]notify.Route, error) {
+	var (
+		routes   []notify.Route
+		template *notify.Template
+		err      error
+	)
+
+	if cfg.templateFile != "" {
+		if template, err = notify.LoadTemplate(cfg.templateFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.webhookUrl != "" {
+		var (
+			notifyer notify.Notifyer
+			err      error
+		)
+		if cfg.discordQuietPeriod > 0 {
+			notifyer, err = notify.NewDiscordLiveNotifyer(cfg.webhookUrl, cfg.discordQuietPeriod)
+		} else {
+			notifyer, err = notify.NewDiscordNotifyer(cfg.webhookUrl)
+		}
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, notify.Route{Name: "discord", Notifyer: notifyer})
+	}
+
+	if cfg.telegramToken != "" {
+		notifyer, err := notify.NewTelegramNotifyer(cfg.telegramToken, cfg.telegramChats, cfg.telegramApi)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, perChatRoutes(notify.Route{Name: "telegram", Notifyer: notifyer})...)
+	}
+
+	if cfg.slackWebhook != "" {
+		notifyer, err := notify.NewSlackNotifyer(cfg.slackWebhook)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, notify.Route{Name: "slack", Notifyer: notifyer})
+	}
+
+	if cfg.jsonWebhook != "" {
+		notifyer, err := notify.NewWebhookNotifyer(cfg.jsonWebhook, notify.WebhookOptions{
+			Headers: cfg.jsonWebhookHeaders,
+			Secret:  cfg.jsonWebhookSecret,
+			Timeout: cfg.jsonWebhookTimeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, notify.Route{Name: "json-webhook", Notifyer: notifyer})
+	}
+
+	if cfg.smtp.Host != "" {
+		security, err := notify.ParseSMTPSecurity(cfg.smtpSecurity)
+		if err != nil {
+			return nil, err
+		}
+		options := cfg.smtp
+		options.Security = security
+		notifyer, err := notify.NewSMTPNotifyer(options)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, notify.Route{Name: "smtp", Notifyer: notifyer})
+	}
+
+	if cfg.ntfyUrl != "" {
+		notifyer, err := notify.NewNtfyNotifyer(cfg.ntfyUrl, cfg.ntfyTopic, cfg.ntfyToken)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, notify.Route{Name: "ntfy", Notifyer: notifyer})
+	}
+
+	if cfg.gotifyUrl != "" {
+		notifyer, err := notify.NewGotifyNotifyer(cfg.gotifyUrl, cfg.gotifyToken)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, notify.Route{Name: "gotify", Notifyer: notifyer})
+	}
+
+	if cfg.mqtt.Broker != "" {
+		notifyer, err := notify.NewMQTTNotifyer(cfg.mqtt)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, notify.Route{Name: "mqtt", Notifyer: notifyer})
+	}
+
+	if cfg.redis.Url != "" {
+		mode, err := notify.ParseRedisMode(cfg.redisMode)
+		if err != nil {
+			return nil, err
+		}
+		options := cfg.redis
+		options.Mode = mode
+		notifyer, err := notify.NewRedisNotifyer(options)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, notify.Route{Name: "redis", Notifyer: notifyer})
+	}
+
+	if cfg.nats.Url != "" {
+		notifyer, err := notify.NewNATSNotifyer(cfg.nats)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, notify.Route{Name: "nats", Notifyer: notifyer})
+	}
+
+	if len(cfg.kafka.Brokers) > 0 {
+		notifyer, err := notify.NewKafkaNotifyer(cfg.kafka)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, notify.Route{Name: "kafka", Notifyer: notifyer})
+	}
+
+	for i := range routes {
+		setTemplate(routes[i].Notifyer, template)
+	}
+
+	for i, notifier := range configured {
+		if notifier.Name == "" {
+			notifier.Name = fmt.Sprintf("%s-%d", notifier.Type, i)
+		}
+		notifyer, err := notifier.notifyer()
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: %w", notifier.Name, err)
+		}
+
+		notifierTemplate := template
+		if notifier.Template != "" {
+			if notifierTemplate, err = notify.LoadTemplate(notifier.Template); err != nil {
+				return nil, fmt.Errorf("notifier %s: %w", notifier.Name, err)
+			}
+		}
+		setTemplate(notifyer, notifierTemplate)
+
+		for _, route := range perChatRoutes(notify.Route{Name: notifier.Name, Notifyer: notifyer, Rules: notifier.Rules}) {
+			if notifier.Digest != nil {
+				if route.Notifyer, err = digestNotifyer(route.Name, route.Notifyer, *notifier.Digest); err != nil {
+					return nil, fmt.Errorf("notifier %s: %w", notifier.Name, err)
+				}
+			}
+			routes = append(routes, route)
+		}
+	}
+
+	return routes, nil
+}
+
+// perChatRoutes splits the route of a telegram notifyer with many chats into a route for each chat named name:chat,
+// so they are queued apart and a chat failing doesn't make the others get the restock again
+func perChatRoutes(route notify.Route) []notify.Route {
+	telegram, ok := route.Notifyer.(*notify.TelegramNotifyer)
+	if !ok || len(telegram.ChatIDs()) < 2 {
+		return []notify.Route{route}
+	}
+
+	name := route.Name
+	routes := make([]notify.Route, 0, len(telegram.ChatIDs()))
+	for _, chat := range telegram.PerChat() {
+		route.Name = name + ":" + chat.ChatIDs()[0]
+		route.Notifyer = chat
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// digestNotifyer wraps notifyer so it gets digests of the restocks, only notifyers sending messages to people support them
+func digestNotifyer(name string, notifyer notify.Notifyer, digest digestConfig) (notify.Notifyer, error) {
+	messageNotifyer, ok := notifyer.(notify.MessageNotifyer)
+	if !ok {
+		return nil, errors.New("digests are not supported by this notifier type")
+	}
+
+	var interval time.Duration
+	if digest.Interval != "" {
+		var err error
+		if interval, err = time.ParseDuration(digest.Interval); err != nil {
+			return nil, fmt.Errorf("invalid digest interval: %w", err)
+		}
+	}
+
+	return notify.NewDigestNotifyer(messageNotifyer, notify.DigestOptions{
+		Interval: interval,
+		MaxItems: digest.MaxItems,
+		OnError: func(err error) {
+			var permanent *notify.PermanentError
+			if errors.As(err, &permanent) {
+				log.Error().Err(err).Str("notifier", name).Msg("Failed to send digest, dropping it.")
+				return
+			}
+			log.Error().Err(err).Str("notifier", name).Msg("Failed to send digest, keeping it for the next one.")
+		},
+	})
+}
+
+// closeNotifyers sends what queues and digests are holding and closes the connections of the routes
+func closeNotifyers(routes []notify.Route) {
+	for _, route := range routes {
+		if closer, ok := route.Notifyer.(interface{ Close() }); ok {
+			closer.Close()
+		}
+	}
+}
+
+// setTemplate sets the template of notifyers that support them, nil keeps the default one
+func setTemplate(notifyer notify.Notifyer, template *notify.Template) {
+	if templater, ok := notifyer.(notify.Templater); ok && template != nil {
+		templater.SetTemplate(template)
+	}
+}
+
+// queueNotifyers puts a retrying queue in front of the notifyer of every route
+func queueNotifyers(routes []notify.Route) []notify.Route {
+	queued := make([]notify.Route, len(routes))
+	for i, route := range routes {
+		route.Notifyer = queueNotifyer(route.Name, route.Notifyer)
+		queued[i] = route
+	}
+
+	return queued
+}
+
+// queueNotifyer puts a retrying queue in front of notifyer, logging failures with its name
+func queueNotifyer(name string, notifyer notify.Notifyer) notify.Notifyer {
+	return newQueue(name, notifyer, cfg.deadLetters)
+}
+
+// queueBotNotifyer puts a retrying queue in front of the notifyer of a bot chat, named bot:platform:chat.
+// Its failures are only logged, replay-dlq can't create the notifyers of chats to resend them
+func queueBotNotifyer(name string, notifyer notify.Notifyer) notify.Notifyer {
+	return newQueue("bot:"+name, notifyer, nil)
+}
+
+// newQueue puts a retrying queue in front of notifyer, logging failures with its name and writing the restocks that failed to deadLetters if it's not nil
+func newQueue(name string, notifyer notify.Notifyer, deadLetters *notify.DeadLetterFile) notify.Notifyer {
+	// Discord coalesces the restocks being sent at the same time into a single message
+	workers := 1
+	if _, ok := notifyer.(*notify.DiscordNotifyer); ok {
+		workers = notify.DiscordBatchSize
+	}
+
+	return notify.NewQueue(name, notifyer, notify.QueueOptions{
+		Workers:     workers,
+		MaxAttempts: cfg.notifyAttempts,
+		BaseDelay:   cfg.notifyBackoff,
+		DeadLetters: deadLetters,
+		OnError: func(attempt int, err error, final bool) {
+			if final {
+				log.Error().Err(err).Str("notifier", name).Int("attempt", attempt).Msg("Giving up on notification.")
+			} else {
+				log.Warn().Err(err).Str("notifier", name).Int("attempt", attempt).Msg("Notification failed, retrying.")
+			}
+		},
+	})
+}
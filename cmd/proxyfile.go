@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rodjunger/nkmonitor"
+	"github.com/rs/zerolog/log"
+)
+
+// readProxyFile reads one proxy per line, blank lines and lines starting with # are ignored
+func readProxyFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var proxies []string
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		proxies = append(proxies, line)
+	}
+	return proxies, sc.Err()
+}
+
+// watchProxyFile polls the proxy file every interval and replaces the monitor's proxies when it changes.
+// The proxies given by flag are always kept. An invalid file is logged and ignored, keeping the current proxies.
+func watchProxyFile(monitor *nkmonitor.Monitor, path string, interval time.Duration, flagProxies []string) {
+	var lastMod time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	for range time.Tick(interval) {
+		info, err := os.Stat(path)
+		if err != nil {
+			log.Warn().Err(err).Str("file", path).Msg("Could not stat proxy file.")
+			continue
+		}
+
+		if info.ModTime().Equal(lastMod) {
+			continue
+		}
+		lastMod = info.ModTime()
+
+		fileProxies, err := readProxyFile(path)
+		if err != nil {
+			log.Warn().Err(err).Str("file", path).Msg("Could not read proxy file.")
+			continue
+		}
+
+		if err := monitor.SetProxies(append(append([]string{}, flagProxies...), fileProxies...)); err != nil {
+			log.Warn().Err(err).Str("file", path).Msg("Invalid proxy file, keeping current proxies.")
+			continue
+		}
+
+		log.Info().Str("file", path).Int("proxies", len(fileProxies)).Msg("Proxy file reloaded.")
+	}
+}
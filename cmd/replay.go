@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/rodjunger/nkmonitor/cmd/notify"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// replayCmd resends the restocks of the dead letter file
+var replayCmd = &cobra.Command{
+	Use:   "replay-dlq",
+	Short: "resends the restocks in the dead letter file",
+	Long: "resends every restock in the dead letter file to the notifier it failed on, using the same notifier flags and config file as the monitor. " +
+		"Restocks that fail again are kept in the file.",
+	RunE: replayDeadLetters,
+}
+
+func replayDeadLetters(cmd *cobra.Command, args []string) (err error) {
+	defer func() {
+		if err != nil {
+			log.Error().Err(err).Msg("")
+		}
+	}()
+
+	if cfg.deadLetterFile == "" {
+		return errors.New("no dead letter file")
+	}
+
+	letters, err := notify.ReadDeadLetters(cfg.deadLetterFile)
+	if os.IsNotExist(err) {
+		log.Info().Msg("No dead letters to replay.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if cfg.configFile != "" {
+		fileCfg, err := loadConfigFile(cfg.configFile)
+		if err != nil {
+			return err
+		}
+		cfg.notifiers = fileCfg.Notifiers
+	}
+
+	routes, err := buildNotifyers(cfg.notifiers)
+	if err != nil {
+		return err
+	}
+
+	notifyers := make(map[string]notify.Notifyer, len(routes))
+	for _, route := range routes {
+		notifyers[route.Name] = route.Notifyer
+	}
+
+	var failed []notify.DeadLetter
+	for _, letter := range letters {
+		notifyer, ok := notifyers[letter.Notifier]
+		if !ok {
+			log.Warn().Str("notifier", letter.Notifier).Str("product", letter.Restock.Name).Msg("Notifier not configured, keeping restock.")
+			failed = append(failed, letter)
+			continue
+		}
+
+		if err := notifyer.Notify(letter.Restock); err != nil {
+			log.Error().Err(err).Str("notifier", letter.Notifier).Str("product", letter.Restock.Name).Msg("Failed to resend.")
+			letter.Attempts++
+			letter.Error = err.Error()
+			letter.FailedAt = time.Now()
+			failed = append(failed, letter)
+			continue
+		}
+
+		log.Info().Str("notifier", letter.Notifier).Str("product", letter.Restock.Name).Msg("Resent.")
+	}
+
+	// Digests are only sent when closed
+	closeNotifyers(routes)
+
+	log.Info().Int("resent", len(letters)-len(failed)).Int("failed", len(failed)).Msg("Replay finished.")
+
+	return notify.WriteDeadLetters(cfg.deadLetterFile, failed)
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+}
@@ -0,0 +1,179 @@
+package nkmonitor
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// DedupeOptions configures how flapping inventory is kept from triggering repeated restocks, the zero value disables it
+type DedupeOptions struct {
+	// ProductCooldown suppresses restocks of a product within this window of its last restock
+	ProductCooldown time.Duration
+	// SizeCooldown keeps sizes from being flagged as restocked within this window of their last restock,
+	// restocks without any other restocked size are suppressed
+	SizeCooldown time.Duration
+	// MinStablePolls is how many polls in a row a size must keep a new stock state before the change counts, 0 and 1 count it right away
+	MinStablePolls int
+}
+
+func (o DedupeOptions) validate() error {
+	if o.ProductCooldown < 0 || o.SizeCooldown < 0 {
+		return errors.New("cooldowns can't be negative")
+	}
+	if o.MinStablePolls < 0 {
+		return errors.New("min stable polls can't be negative")
+	}
+	return nil
+}
+
+// DedupeStats counts the restocks suppressed by the DedupeOptions since the monitor was created
+type DedupeStats struct {
+	Unstable        uint64 // Unstable is how many size restocks were dropped because the size changed back before MinStablePolls
+	ProductCooldown uint64 // ProductCooldown is how many restocks were suppressed by ProductCooldown
+	SizeCooldown    uint64 // SizeCooldown is how many restocks were suppressed because all their sizes were in SizeCooldown
+}
+
+type dedupeCounters struct {
+	unstable        atomic.Uint64
+	productCooldown atomic.Uint64
+	sizeCooldown    atomic.Uint64
+}
+
+// WithDedupe suppresses restocks caused by flapping inventory, see DedupeOptions
+func WithDedupe(options DedupeOptions) Option {
+	return func(m *Monitor) error {
+		if err := options.validate(); err != nil {
+			return err
+		}
+		m.dedupe = options
+		return nil
+	}
+}
+
+// DedupeStats returns how many restocks were suppressed by WithDedupe
+func (m *Monitor) DedupeStats() DedupeStats {
+	return DedupeStats{
+		Unstable:        m.suppressed.unstable.Load(),
+		ProductCooldown: m.suppressed.productCooldown.Load(),
+		SizeCooldown:    m.suppressed.sizeCooldown.Load(),
+	}
+}
+
+type sizeState struct {
+	available bool
+	inStock   bool
+}
+
+// restockedFrom reports whether going from previous to s is a restock: the size became available or got stock
+func (s sizeState) restockedFrom(previous sizeState) bool {
+	return s.available && !previous.available || s.inStock && !previous.inStock
+}
+
+// sizeTracker keeps the stock state of the sizes of a product, a new state is only confirmed after minStablePolls polls in a row
+type sizeTracker struct {
+	minStablePolls int
+	confirmed      map[string]sizeState
+	pending        map[string]sizeState
+	pendingPolls   map[string]int
+	unstable       *atomic.Uint64
+}
+
+func newSizeTracker(minStablePolls int, unstable *atomic.Uint64) *sizeTracker {
+	if minStablePolls < 1 {
+		minStablePolls = 1
+	}
+	return &sizeTracker{
+		minStablePolls: minStablePolls,
+		confirmed:      map[string]sizeState{},
+		pending:        map[string]sizeState{},
+		pendingPolls:   map[string]int{},
+		unstable:       unstable,
+	}
+}
+
+// observe records the state of a size in a poll, returns whether it is a confirmed restock
+func (t *sizeTracker) observe(sku string, observed sizeState) bool {
+	confirmed := t.confirmed[sku]
+
+	if pending, ok := t.pending[sku]; ok && pending != observed {
+		// The size changed again before being confirmed
+		if pending.restockedFrom(confirmed) {
+			t.unstable.Inc()
+		}
+		delete(t.pending, sku)
+		delete(t.pendingPolls, sku)
+	}
+
+	if observed == confirmed {
+		return false
+	}
+
+	t.pending[sku] = observed
+	t.pendingPolls[sku]++
+	if t.pendingPolls[sku] < t.minStablePolls {
+		return false
+	}
+
+	delete(t.pending, sku)
+	delete(t.pendingPolls, sku)
+	t.confirmed[sku] = observed
+
+	return observed.restockedFrom(confirmed)
+}
+
+// deduper applies the cooldowns of DedupeOptions to restocks, it's only used by mainLoop
+type deduper struct {
+	options     DedupeOptions
+	lastProduct map[string]time.Time // lastProduct has the time of the last restock of every product path
+	lastSize    map[string]time.Time // lastSize has the time of the last restock of every SKU
+	counters    *dedupeCounters
+}
+
+func newDeduper(options DedupeOptions, counters *dedupeCounters) *deduper {
+	return &deduper{
+		options:     options,
+		lastProduct: map[string]time.Time{},
+		lastSize:    map[string]time.Time{},
+		counters:    counters,
+	}
+}
+
+// filter clears the restocked flag of sizes in cooldown and reports whether the restock should be sent
+func (d *deduper) filter(info *RestockInfo) bool {
+	now := info.DetectedAt
+
+	fresh := 0
+	for i, size := range info.Sizes {
+		if !size.Restocked {
+			continue
+		}
+		if last, ok := d.lastSize[size.Sku]; ok && now.Sub(last) < d.options.SizeCooldown {
+			unflagged := *size
+			unflagged.Restocked = false
+			info.Sizes[i] = &unflagged
+			continue
+		}
+		fresh++
+	}
+
+	if fresh == 0 {
+		d.counters.sizeCooldown.Inc()
+		return false
+	}
+
+	if last, ok := d.lastProduct[info.Path]; ok && now.Sub(last) < d.options.ProductCooldown {
+		d.counters.productCooldown.Inc()
+		return false
+	}
+
+	d.lastProduct[info.Path] = now
+	for _, size := range info.Sizes {
+		if size.Restocked {
+			d.lastSize[size.Sku] = now
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,110 @@
+package nkmonitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestDedupeOptionsValidate(t *testing.T) {
+	assert.NoError(t, DedupeOptions{}.validate())
+	assert.NoError(t, DedupeOptions{ProductCooldown: time.Minute, SizeCooldown: time.Hour, MinStablePolls: 3}.validate())
+	assert.Error(t, DedupeOptions{ProductCooldown: -time.Second}.validate())
+	assert.Error(t, DedupeOptions{SizeCooldown: -time.Second}.validate())
+	assert.Error(t, DedupeOptions{MinStablePolls: -1}.validate())
+}
+
+func TestSizeTracker(t *testing.T) {
+	var (
+		inStock    = sizeState{inStock: true}
+		available  = sizeState{inStock: true, available: true}
+		outOfStock = sizeState{}
+	)
+
+	t.Run("WithoutMinStablePolls", func(t *testing.T) {
+		unstable := &atomic.Uint64{}
+		tracker := newSizeTracker(0, unstable)
+
+		assert.True(t, tracker.observe("1", inStock))
+		assert.False(t, tracker.observe("1", inStock), "unchanged sizes are not restocks")
+		assert.True(t, tracker.observe("1", available), "becoming available is a restock")
+		assert.False(t, tracker.observe("1", outOfStock))
+		assert.True(t, tracker.observe("1", inStock))
+		assert.Zero(t, unstable.Load())
+	})
+
+	t.Run("WithFlapping", func(t *testing.T) {
+		unstable := &atomic.Uint64{}
+		tracker := newSizeTracker(3, unstable)
+
+		for i := 0; i < 4; i++ {
+			assert.False(t, tracker.observe("1", inStock))
+			assert.False(t, tracker.observe("1", outOfStock))
+		}
+		assert.Equal(t, uint64(4), unstable.Load(), "every dropped restock should be counted")
+	})
+
+	t.Run("WithStableRestock", func(t *testing.T) {
+		unstable := &atomic.Uint64{}
+		tracker := newSizeTracker(3, unstable)
+
+		assert.False(t, tracker.observe("1", inStock))
+		assert.False(t, tracker.observe("1", inStock))
+		assert.True(t, tracker.observe("1", inStock), "the restock should count on the third poll in a row")
+		assert.False(t, tracker.observe("1", inStock))
+
+		// Going out of stock for less than 3 polls doesn't count, so coming back isn't a restock
+		assert.False(t, tracker.observe("1", outOfStock))
+		assert.False(t, tracker.observe("1", outOfStock))
+		assert.False(t, tracker.observe("1", inStock))
+		assert.False(t, tracker.observe("1", inStock))
+		assert.Zero(t, unstable.Load())
+
+		assert.False(t, tracker.observe("2", available), "sizes are tracked separately")
+	})
+}
+
+func TestDeduper(t *testing.T) {
+	start := time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC)
+	restock := func(at time.Duration, path string, skus ...string) *RestockInfo {
+		info := &RestockInfo{Path: path, DetectedAt: start.Add(at)}
+		for _, sku := range skus {
+			info.Sizes = append(info.Sizes, &SizeInfo{Sku: sku, HasStock: true, Restocked: true})
+		}
+		return info
+	}
+
+	t.Run("WithoutCooldowns", func(t *testing.T) {
+		deduper := newDeduper(DedupeOptions{}, &dedupeCounters{})
+		assert.True(t, deduper.filter(restock(0, "/a", "1")))
+		assert.True(t, deduper.filter(restock(0, "/a", "1")))
+	})
+
+	t.Run("WithProductCooldown", func(t *testing.T) {
+		counters := &dedupeCounters{}
+		deduper := newDeduper(DedupeOptions{ProductCooldown: time.Minute}, counters)
+
+		assert.True(t, deduper.filter(restock(0, "/a", "1")))
+		assert.False(t, deduper.filter(restock(30*time.Second, "/a", "2")))
+		assert.True(t, deduper.filter(restock(30*time.Second, "/b", "3")), "products have their own cooldown")
+		assert.True(t, deduper.filter(restock(time.Minute, "/a", "2")), "the cooldown starts at the last restock sent")
+		assert.Equal(t, uint64(1), counters.productCooldown.Load())
+	})
+
+	t.Run("WithSizeCooldown", func(t *testing.T) {
+		counters := &dedupeCounters{}
+		deduper := newDeduper(DedupeOptions{SizeCooldown: time.Minute}, counters)
+
+		assert.True(t, deduper.filter(restock(0, "/a", "1")))
+		assert.False(t, deduper.filter(restock(10*time.Second, "/a", "1")))
+
+		info := restock(20*time.Second, "/a", "1", "2")
+		assert.True(t, deduper.filter(info), "restocks with a fresh size should be sent")
+		assert.False(t, info.Sizes[0].Restocked, "sizes in cooldown should not be flagged")
+		assert.True(t, info.Sizes[1].Restocked)
+
+		assert.Equal(t, uint64(1), counters.sizeCooldown.Load())
+	})
+}
@@ -0,0 +1,118 @@
+package nkmonitor
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// defaultMaxTasksPerEgress is the default amount of products that can be monitored per egress identity
+const defaultMaxTasksPerEgress = 5
+
+var (
+	ErrTooManyTasks      = errors.New("too many tasks per egress identity")
+	ErrInvalidLocalAddr  = errors.New("invalid local address")
+	errNoInterfaceIPAddr = errors.New("interface has no IP address")
+)
+
+// resolveLocalAddr converts an IP or a network interface name to an IP that outgoing connections can be bound to
+func resolveLocalAddr(addr string) (net.IP, error) {
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip, nil
+	}
+
+	iface, err := net.InterfaceByName(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is neither an IP nor an interface", ErrInvalidLocalAddr, addr)
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefer IPv4 since that's what most hosts have routes for
+	var fallback net.IP
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if fallback == nil {
+			fallback = ipNet.IP
+		}
+	}
+
+	if fallback == nil {
+		return nil, fmt.Errorf("%w: %s", errNoInterfaceIPAddr, addr)
+	}
+	return fallback, nil
+}
+
+// nextLocalAddr returns the next local address in round-robin order, nil if none were configured
+func (m *Monitor) nextLocalAddr() net.IP {
+	if len(m.localAddrs) == 0 {
+		return nil
+	}
+	return m.localAddrs[m.curLocalAddrIndex.Inc()%uint64(len(m.localAddrs))]
+}
+
+// localDialer returns a dialer bound to ip
+func localDialer(ip net.IP) *net.Dialer {
+	return &net.Dialer{
+		LocalAddr: &net.TCPAddr{IP: ip},
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+}
+
+// sharesEgress reports whether requests of tasks using proxies and others can come from the same identity,
+// tasks without proxies share the local addresses or the host itself
+func sharesEgress(proxies, others []string) bool {
+	if len(proxies) == 0 || len(others) == 0 {
+		return len(proxies) == len(others)
+	}
+	for _, proxy := range proxies {
+		for _, other := range others {
+			if proxy == other {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkTaskLimit returns ErrTooManyTasks if monitoring one more product with the proxy groups given would go over the limit of tasks per egress identity.
+// The identities are the distinct proxies of the groups when there are any, otherwise the local addresses or the host itself.
+// running has the proxy groups of every product monitored, only the ones sharing identities with the new product count
+func (m *Monitor) checkTaskLimit(groups []string, running map[string][]string) error {
+	if m.maxTasksPerEgress <= 0 {
+		return nil
+	}
+
+	proxies := m.proxies.Keys(groups)
+	identities := len(proxies)
+	if identities == 0 {
+		identities = len(m.localAddrs)
+	}
+	if identities == 0 {
+		identities = 1
+	}
+
+	sharing := 0
+	for _, productGroups := range running {
+		if sharesEgress(proxies, m.proxies.Keys(productGroups)) {
+			sharing++
+		}
+	}
+
+	if sharing+1 > identities*m.maxTasksPerEgress {
+		return fmt.Errorf("%w: %d products are already monitored with %d egress identities (limit %d per identity)",
+			ErrTooManyTasks, sharing, identities, m.maxTasksPerEgress)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package nkmonitor
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/saucesteals/mimic"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveLocalAddr(t *testing.T) {
+	ip, err := resolveLocalAddr("127.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, ip.Equal(net.IPv4(127, 0, 0, 1)))
+
+	_, err = resolveLocalAddr("not-an-interface")
+	assert.ErrorIs(t, err, ErrInvalidLocalAddr)
+}
+
+func TestTaskLimit(t *testing.T) {
+	m, _ := mimic.Chromium(mimic.BrandChrome, "106.0.0.0")
+
+	monitor, err := NewMonitor("not empty", time.Second, nil, m)
+	assert.NoError(t, err)
+	assert.NoError(t, monitor.checkTaskLimit(nil, runningProducts(defaultMaxTasksPerEgress-1)))
+	assert.ErrorIs(t, monitor.checkTaskLimit(nil, runningProducts(defaultMaxTasksPerEgress)), ErrTooManyTasks, "host IP should be a single identity")
+
+	monitor, err = NewMonitor("not empty", time.Second, nil, m, WithLocalAddrs([]string{"127.0.0.1", "127.0.0.2"}), WithMaxTasksPerEgress(2))
+	assert.NoError(t, err)
+	assert.NoError(t, monitor.checkTaskLimit(nil, runningProducts(3)))
+	assert.ErrorIs(t, monitor.checkTaskLimit(nil, runningProducts(4)), ErrTooManyTasks)
+	first, second := monitor.nextLocalAddr(), monitor.nextLocalAddr()
+	assert.False(t, first.Equal(second), "local addresses should be round-robined")
+	assert.True(t, first.Equal(monitor.nextLocalAddr()))
+
+	assert.NoError(t, monitor.AddProxies([]string{"127.0.0.1:8080", "127.0.0.1:8081", "127.0.0.1:8082"}))
+	assert.NoError(t, monitor.checkTaskLimit(defaultProxyGroups, runningProducts(5)), "proxies should replace local addresses as identities")
+	assert.ErrorIs(t, monitor.checkTaskLimit(defaultProxyGroups, runningProducts(6)), ErrTooManyTasks)
+
+	monitor, err = NewMonitor("not empty", time.Second, nil, m, WithoutTaskLimit())
+	assert.NoError(t, err)
+	assert.NoError(t, monitor.checkTaskLimit(nil, runningProducts(1000)))
+
+	_, err = NewMonitor("not empty", time.Second, nil, m, WithMaxTasksPerEgress(0))
+	assert.Error(t, err)
+}
+
+func TestTaskLimitGroups(t *testing.T) {
+	m, _ := mimic.Chromium(mimic.BrandChrome, "106.0.0.0")
+
+	monitor, err := NewMonitor("not empty", time.Second, nil, m, WithMaxTasksPerEgress(1))
+	assert.NoError(t, err)
+	assert.NoError(t, monitor.SetGroupProxies("premium", []string{"127.0.0.1:8080"}))
+	assert.NoError(t, monitor.SetGroupProxies("cheap", []string{"127.0.0.1:8080", "127.0.0.1:8081"}))
+	assert.NoError(t, monitor.SetGroupProxies("other", []string{"127.0.0.1:9090"}))
+
+	running := map[string][]string{"/a": {"premium"}}
+	assert.NoError(t, monitor.checkTaskLimit([]string{"premium", "cheap"}, running), "the fallback group should add its proxies")
+	assert.ErrorIs(t, monitor.checkTaskLimit([]string{"premium"}, running), ErrTooManyTasks, "the task should only use the proxies of its groups")
+
+	running["/b"] = []string{"cheap"}
+	assert.ErrorIs(t, monitor.checkTaskLimit([]string{"premium", "cheap"}, running), ErrTooManyTasks, "proxies in both groups should be counted once")
+	assert.NoError(t, monitor.checkTaskLimit([]string{"other"}, running), "products without shared proxies should not count")
+}
+
+// runningProducts returns n products monitored with the default proxy group
+func runningProducts(n int) map[string][]string {
+	running := make(map[string][]string, n)
+	for i := 0; i < n; i++ {
+		running[fmt.Sprintf("/product-%d", i)] = defaultProxyGroups
+	}
+	return running
+}
@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultGroup is the group used when no group is specified
+const DefaultGroup = "default"
+
+// Groups is a concurrency safe set of named pools, so different kinds of proxies can be used for different tasks.
+// The health of a proxy is tracked per group.
+type Groups struct {
+	lock  sync.RWMutex
+	pools map[string]*Pool
+}
+
+// NewGroups creates a Groups with an empty default group
+func NewGroups() *Groups {
+	return &Groups{pools: map[string]*Pool{DefaultGroup: NewPool(nil)}}
+}
+
+// pool returns the pool of a group, nil if the group doesn't exist. Only Set and Add create groups
+func (g *Groups) pool(name string) *Pool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return g.pools[name]
+}
+
+// create returns the pool of a group, creating it if it doesn't exist
+func (g *Groups) create(name string) *Pool {
+	if pool := g.pool(name); pool != nil {
+		return pool
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	pool, ok := g.pools[name]
+	if !ok {
+		pool = NewPool(nil)
+		g.pools[name] = pool
+	}
+	return pool
+}
+
+// each calls f for every group, in name order
+func (g *Groups) each(f func(name string, pool *Pool)) {
+	g.lock.RLock()
+	names := make([]string, 0, len(g.pools))
+	for name := range g.pools {
+		names = append(names, name)
+	}
+	pools := make([]*Pool, 0, len(g.pools))
+	sort.Strings(names)
+	for _, name := range names {
+		pools = append(pools, g.pools[name])
+	}
+	g.lock.RUnlock()
+
+	for i, pool := range pools {
+		f(names[i], pool)
+	}
+}
+
+// Set replaces the proxies of a group, see Pool.Set
+func (g *Groups) Set(group string, proxies []*Proxy) {
+	g.create(group).Set(proxies)
+}
+
+// Add adds proxies to a group, see Pool.Add
+func (g *Groups) Add(group string, proxies []*Proxy) {
+	g.create(group).Add(proxies)
+}
+
+// Has returns whether a group exists, groups are created by Set and Add
+func (g *Groups) Has(group string) bool {
+	return g.pool(group) != nil
+}
+
+// Remove removes a proxy from every group, returns false if the proxy was not in any group
+func (g *Groups) Remove(proxy *Proxy) bool {
+	removed := false
+	g.each(func(_ string, pool *Pool) {
+		if pool.Remove(proxy) {
+			removed = true
+		}
+	})
+	return removed
+}
+
+// Contains returns whether proxy is in any of the given groups
+func (g *Groups) Contains(groups []string, proxy *Proxy) bool {
+	for _, group := range groups {
+		if pool := g.pool(group); pool != nil && pool.Contains(proxy) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the amount of proxies in the given groups, proxies in more than one group are counted once per group
+func (g *Groups) Len(groups []string) int {
+	total := 0
+	for _, group := range groups {
+		if pool := g.pool(group); pool != nil {
+			total += pool.Len()
+		}
+	}
+	return total
+}
+
+// Keys returns the distinct proxies in the given groups as strings, proxies in more than one group are returned once
+func (g *Groups) Keys(groups []string) []string {
+	var (
+		keys []string
+		seen = map[string]bool{}
+	)
+	for _, group := range groups {
+		pool := g.pool(group)
+		if pool == nil {
+			continue
+		}
+		for _, key := range pool.keys() {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// Next returns the next proxy of the first group in groups that has a proxy that isn't benched, falling back
+// to the next group when one is exhausted. Groups that don't exist are skipped. If every proxy is benched, the first non empty group is used.
+func (g *Groups) Next(groups []string, sel Selection) (*Proxy, error) {
+	var fallback *Pool
+
+	for _, group := range groups {
+		pool := g.pool(group)
+		if pool == nil {
+			continue
+		}
+		if pool.Available() > 0 {
+			return pool.Next(sel)
+		}
+		if fallback == nil && pool.Len() > 0 {
+			fallback = pool
+		}
+	}
+
+	if fallback == nil {
+		return nil, ErrNoProxies
+	}
+	return fallback.Next(sel)
+}
+
+// ReportSuccess reports a success to every group that contains proxy, see Pool.ReportSuccess
+func (g *Groups) ReportSuccess(proxy *Proxy, latency time.Duration) {
+	g.each(func(_ string, pool *Pool) {
+		pool.ReportSuccess(proxy, latency)
+	})
+}
+
+// ReportForbidden reports a 403 to every group that contains proxy, returns whether the proxy got benched in any of them
+func (g *Groups) ReportForbidden(proxy *Proxy) bool {
+	benched := false
+	g.each(func(_ string, pool *Pool) {
+		if pool.ReportForbidden(proxy) {
+			benched = true
+		}
+	})
+	return benched
+}
+
+// ReportError reports an error to every group that contains proxy, returns whether the proxy got benched in any of them
+func (g *Groups) ReportError(proxy *Proxy) bool {
+	benched := false
+	g.each(func(_ string, pool *Pool) {
+		if pool.ReportError(proxy) {
+			benched = true
+		}
+	})
+	return benched
+}
+
+// Stats returns a snapshot of the health of every proxy in every group
+func (g *Groups) Stats() []Stats {
+	var stats []Stats
+	g.each(func(name string, pool *Pool) {
+		for _, s := range pool.Stats() {
+			s.Group = name
+			stats = append(stats, s)
+		}
+	})
+	return stats
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroups(t *testing.T) {
+	var (
+		premium = mustProxies(t, "127.0.0.1:8080")
+		cheap   = mustProxies(t, "127.0.0.1:9090", "127.0.0.1:9091")
+		groups  = NewGroups()
+		order   = []string{"premium", "cheap"}
+	)
+
+	_, err := groups.Next(order, SelectHealthiest)
+	assert.ErrorIs(t, err, ErrNoProxies, "empty groups should return ErrNoProxies")
+
+	groups.Set("premium", premium)
+	groups.Set("cheap", cheap)
+
+	t.Run("UsesFirstGroup", func(t *testing.T) {
+		next, err := groups.Next(order, SelectHealthiest)
+		assert.NoError(t, err)
+		assert.Equal(t, premium[0], next)
+		assert.True(t, groups.Contains(order, next))
+		assert.False(t, groups.Contains([]string{"cheap"}, next))
+	})
+
+	t.Run("FallsBackWhenExhausted", func(t *testing.T) {
+		for i := 0; i < forbiddenThreshold; i++ {
+			groups.ReportForbidden(premium[0])
+		}
+		next, err := groups.Next(order, SelectHealthiest)
+		assert.NoError(t, err)
+		assert.Contains(t, cheap, next)
+	})
+
+	t.Run("UsesBenchedWhenEverythingIsExhausted", func(t *testing.T) {
+		next, err := groups.Next([]string{"premium", "unknown"}, SelectHealthiest)
+		assert.NoError(t, err)
+		assert.Equal(t, premium[0], next)
+	})
+
+	t.Run("RemovesFromEveryGroup", func(t *testing.T) {
+		groups.Add(DefaultGroup, cheap[:1])
+		assert.Equal(t, 3, groups.Len([]string{DefaultGroup, "cheap"}))
+		assert.Len(t, groups.Keys([]string{DefaultGroup, "cheap"}), 2, "proxies in both groups should be counted once")
+		assert.True(t, groups.Remove(cheap[0]))
+		assert.Equal(t, 1, groups.Len([]string{DefaultGroup, "cheap"}))
+		assert.False(t, groups.Remove(cheap[0]))
+	})
+
+	t.Run("ReadsDontCreateGroups", func(t *testing.T) {
+		unknown := []string{"unknown"}
+		assert.False(t, groups.Contains(unknown, premium[0]))
+		assert.Zero(t, groups.Len(unknown))
+		_, err := groups.Next(unknown, SelectHealthiest)
+		assert.ErrorIs(t, err, ErrNoProxies)
+		assert.False(t, groups.Has("unknown"))
+		assert.True(t, groups.Has(DefaultGroup))
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		stats := groups.Stats()
+		assert.Len(t, stats, 2)
+		assert.Equal(t, "cheap", stats[0].Group)
+		assert.Equal(t, "premium", stats[1].Group)
+	})
+}
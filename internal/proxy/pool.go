@@ -0,0 +1,370 @@
+package proxy
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// forbiddenThreshold is the amount of consecutive 403s after which a proxy is benched
+	forbiddenThreshold = 2
+	// errorThreshold is the amount of consecutive errors (timeouts, refused connections...) after which a proxy is benched
+	errorThreshold = 3
+	// baseCooldown is how long a proxy is benched the first time, it doubles on every consecutive bench
+	baseCooldown = time.Minute
+	// maxCooldown caps the exponential cooldown
+	maxCooldown = 30 * time.Minute
+	// latencyWeight is the weight of the newest sample in the latency moving average
+	latencyWeight = 0.2
+)
+
+var (
+	ErrNoProxies = errors.New("no proxies available")
+)
+
+// Selection defines how Pool.Next picks the next proxy, benched proxies are always skipped
+type Selection int
+
+const (
+	// SelectHealthiest walks the proxies in round-robin order, picking the healthier out of the next two
+	SelectHealthiest Selection = iota
+	// SelectRoundRobin walks the proxies in round-robin order
+	SelectRoundRobin
+	// SelectRandom picks a random proxy
+	SelectRandom
+	// SelectLeastRecentlyUsed picks the proxy that was handed out the longest time ago
+	SelectLeastRecentlyUsed
+)
+
+// Stats is a snapshot of the health of a single proxy in a Pool
+type Stats struct {
+	Group                string        // Group is the name of the group the proxy belongs to
+	Proxy                string        // Proxy is the host:port of the proxy, credentials are never exposed
+	Successes            uint64        // Successes is the amount of requests that got a valid response
+	Failures             uint64        // Failures is the amount of requests that errored or got a 403
+	ConsecutiveForbidden int           // ConsecutiveForbidden is the amount of 403s since the last success
+	ConsecutiveErrors    int           // ConsecutiveErrors is the amount of errors since the last success
+	AverageLatency       time.Duration // AverageLatency is an exponential moving average of successful requests
+	Score                float64       // Score goes from 0 to 1, higher is healthier
+	Bans                 int           // Bans is the amount of times in a row the proxy was benched
+	BenchedUntil         time.Time     // BenchedUntil is zero or the time the proxy leaves the bench
+}
+
+type entry struct {
+	proxy                *Proxy
+	successes            uint64
+	failures             uint64
+	consecutiveForbidden int
+	consecutiveErrors    int
+	avgLatency           time.Duration
+	bans                 int
+	benchedUntil         time.Time
+	lastUsed             time.Time
+}
+
+// score returns the smoothed success rate penalized by latency, a proxy that was never used scores 0.5
+func (e *entry) score() float64 {
+	rate := float64(e.successes+1) / float64(e.successes+e.failures+2)
+	return rate / (1 + e.avgLatency.Seconds()/10)
+}
+
+func (e *entry) benched(now time.Time) bool {
+	return now.Before(e.benchedUntil)
+}
+
+func (e *entry) bench(now time.Time) {
+	cooldown := baseCooldown << e.bans
+	if cooldown > maxCooldown || cooldown <= 0 {
+		cooldown = maxCooldown
+	}
+	e.bans++
+	e.benchedUntil = now.Add(cooldown)
+	e.consecutiveErrors = 0
+	e.consecutiveForbidden = 0
+}
+
+func (e *entry) stats() Stats {
+	return Stats{
+		Proxy:                e.proxy.Host,
+		Successes:            e.successes,
+		Failures:             e.failures,
+		ConsecutiveForbidden: e.consecutiveForbidden,
+		ConsecutiveErrors:    e.consecutiveErrors,
+		AverageLatency:       e.avgLatency,
+		Score:                e.score(),
+		Bans:                 e.bans,
+		BenchedUntil:         e.benchedUntil,
+	}
+}
+
+// Pool is a concurrency safe set of proxies that keeps track of each proxy's health.
+// Proxies that keep failing are benched for an exponentially growing cooldown and healthier proxies are preferred.
+type Pool struct {
+	lock    sync.Mutex
+	entries []*entry
+	byKey   map[string]*entry
+	cursor  int
+	now     func() time.Time
+}
+
+// NewPool creates a Pool from a list of proxies, duplicated proxies are ignored
+func NewPool(proxies []*Proxy) *Pool {
+	p := &Pool{byKey: map[string]*entry{}, now: time.Now}
+	for _, proxy := range proxies {
+		p.add(proxy)
+	}
+	return p
+}
+
+func (p *Pool) add(proxy *Proxy) {
+	key := proxy.String()
+	if _, ok := p.byKey[key]; ok || key == "" {
+		return
+	}
+	e := &entry{proxy: proxy}
+	p.entries = append(p.entries, e)
+	p.byKey[key] = e
+}
+
+// Set replaces the proxies in the pool, the health of proxies that stay in the pool is kept
+func (p *Pool) Set(proxies []*Proxy) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	old := p.byKey
+	p.entries = nil
+	p.byKey = map[string]*entry{}
+
+	for _, proxy := range proxies {
+		key := proxy.String()
+		if e, ok := old[key]; ok {
+			if _, dup := p.byKey[key]; !dup {
+				p.entries = append(p.entries, e)
+				p.byKey[key] = e
+			}
+			continue
+		}
+		p.add(proxy)
+	}
+
+	if len(p.entries) > 0 {
+		p.cursor %= len(p.entries)
+	} else {
+		p.cursor = 0
+	}
+}
+
+// Add adds proxies to the pool, proxies that are already in the pool are ignored
+func (p *Pool) Add(proxies []*Proxy) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, proxy := range proxies {
+		p.add(proxy)
+	}
+}
+
+// Remove removes a proxy from the pool, returns false if the proxy was not in the pool
+func (p *Pool) Remove(proxy *Proxy) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	e := p.lookup(proxy)
+	if e == nil {
+		return false
+	}
+
+	delete(p.byKey, proxy.String())
+	for i := range p.entries {
+		if p.entries[i] == e {
+			p.entries = append(p.entries[:i], p.entries[i+1:]...)
+			break
+		}
+	}
+
+	if len(p.entries) > 0 {
+		p.cursor %= len(p.entries)
+	} else {
+		p.cursor = 0
+	}
+	return true
+}
+
+// keys returns the proxies in the pool as strings
+func (p *Pool) keys() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	keys := make([]string, len(p.entries))
+	for i, e := range p.entries {
+		keys[i] = e.proxy.String()
+	}
+	return keys
+}
+
+// Contains returns whether proxy is in the pool
+func (p *Pool) Contains(proxy *Proxy) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.lookup(proxy) != nil
+}
+
+// Available returns the amount of proxies in the pool that are not benched
+func (p *Pool) Available() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var (
+		now       = p.now()
+		available int
+	)
+	for _, e := range p.entries {
+		if !e.benched(now) {
+			available++
+		}
+	}
+	return available
+}
+
+// Len returns the amount of proxies in the pool
+func (p *Pool) Len() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.entries)
+}
+
+// Next returns the proxy that should be used for the next client according to sel.
+// If every proxy is benched the one that leaves the bench first is returned.
+func (p *Pool) Next(sel Selection) (*Proxy, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if len(p.entries) == 0 {
+		return nil, ErrNoProxies
+	}
+
+	var (
+		now        = p.now()
+		candidates []*entry
+		chosen     *entry
+	)
+
+	// Candidates are collected in round-robin order starting at the cursor
+	for i := 0; i < len(p.entries); i++ {
+		e := p.entries[(p.cursor+i)%len(p.entries)]
+		if !e.benched(now) {
+			candidates = append(candidates, e)
+		}
+	}
+
+	p.cursor = (p.cursor + 1) % len(p.entries)
+
+	switch {
+	case len(candidates) == 0:
+		for _, e := range p.entries {
+			if chosen == nil || e.benchedUntil.Before(chosen.benchedUntil) {
+				chosen = e
+			}
+		}
+	case sel == SelectRandom:
+		chosen = candidates[rand.Intn(len(candidates))]
+	case sel == SelectLeastRecentlyUsed:
+		for _, e := range candidates {
+			if chosen == nil || e.lastUsed.Before(chosen.lastUsed) {
+				chosen = e
+			}
+		}
+	case sel == SelectHealthiest && len(candidates) > 1:
+		chosen = candidates[0]
+		if candidates[1].score() > chosen.score() {
+			chosen = candidates[1]
+		}
+	default:
+		chosen = candidates[0]
+	}
+
+	chosen.lastUsed = now
+	return chosen.proxy, nil
+}
+
+// lookup returns the entry of a proxy, must be called with the lock held
+func (p *Pool) lookup(proxy *Proxy) *entry {
+	if proxy == nil {
+		return nil
+	}
+	return p.byKey[proxy.String()]
+}
+
+// ReportSuccess records a successful request made through proxy, it's a no-op for proxies that are not in the pool
+func (p *Pool) ReportSuccess(proxy *Proxy, latency time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	e := p.lookup(proxy)
+	if e == nil {
+		return
+	}
+
+	if e.successes == 0 {
+		e.avgLatency = latency
+	} else {
+		e.avgLatency = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(e.avgLatency))
+	}
+	e.successes++
+	e.consecutiveErrors = 0
+	e.consecutiveForbidden = 0
+	e.bans = 0
+}
+
+// ReportForbidden records a 403 received through proxy, returns whether the proxy got benched
+func (p *Pool) ReportForbidden(proxy *Proxy) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	e := p.lookup(proxy)
+	if e == nil {
+		return false
+	}
+
+	e.failures++
+	e.consecutiveForbidden++
+	if e.consecutiveForbidden >= forbiddenThreshold {
+		e.bench(p.now())
+		return true
+	}
+	return false
+}
+
+// ReportError records a failed request (timeout, connection refused...) made through proxy, returns whether the proxy got benched
+func (p *Pool) ReportError(proxy *Proxy) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	e := p.lookup(proxy)
+	if e == nil {
+		return false
+	}
+
+	e.failures++
+	e.consecutiveErrors++
+	if e.consecutiveErrors >= errorThreshold {
+		e.bench(p.now())
+		return true
+	}
+	return false
+}
+
+// Stats returns a snapshot of the health of every proxy in the pool
+func (p *Pool) Stats() []Stats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats := make([]Stats, 0, len(p.entries))
+	for _, e := range p.entries {
+		stats = append(stats, e.stats())
+	}
+	return stats
+}
@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustProxies(t *testing.T, raw ...string) []*Proxy {
+	var proxies []*Proxy
+	for _, r := range raw {
+		p, err := FromString(r)
+		assert.NoError(t, err)
+		proxies = append(proxies, p)
+	}
+	return proxies
+}
+
+func TestPoolNext(t *testing.T) {
+	t.Run("WithEmptyPool", func(t *testing.T) {
+		_, err := NewPool(nil).Next(SelectHealthiest)
+		assert.ErrorIs(t, err, ErrNoProxies)
+	})
+
+	t.Run("WithDuplicates", func(t *testing.T) {
+		pool := NewPool(mustProxies(t, "127.0.0.1:8080", "127.0.0.1:8080"))
+		assert.Equal(t, 1, pool.Len())
+	})
+
+	t.Run("SkipsBenchedProxies", func(t *testing.T) {
+		proxies := mustProxies(t, "127.0.0.1:8080", "127.0.0.1:8081")
+		pool := NewPool(proxies)
+
+		for i := 0; i < forbiddenThreshold-1; i++ {
+			assert.False(t, pool.ReportForbidden(proxies[0]))
+		}
+		assert.True(t, pool.ReportForbidden(proxies[0]), "proxy should be benched after consecutive 403s")
+
+		for i := 0; i < 5; i++ {
+			next, err := pool.Next(SelectHealthiest)
+			assert.NoError(t, err)
+			assert.Equal(t, proxies[1], next)
+		}
+	})
+
+	t.Run("PrefersHealthyProxies", func(t *testing.T) {
+		proxies := mustProxies(t, "127.0.0.1:8080", "127.0.0.1:8081")
+		pool := NewPool(proxies)
+
+		pool.ReportSuccess(proxies[1], 100*time.Millisecond)
+		pool.ReportError(proxies[0])
+
+		next, _ := pool.Next(SelectHealthiest)
+		assert.Equal(t, proxies[1], next)
+	})
+
+	t.Run("WithEveryProxyBenched", func(t *testing.T) {
+		proxies := mustProxies(t, "127.0.0.1:8080", "127.0.0.1:8081")
+		pool := NewPool(proxies)
+
+		for i := 0; i < errorThreshold; i++ {
+			pool.ReportError(proxies[1])
+		}
+		for i := 0; i < errorThreshold; i++ {
+			pool.ReportError(proxies[0])
+		}
+
+		next, err := pool.Next(SelectHealthiest)
+		assert.NoError(t, err)
+		assert.Equal(t, proxies[1], next, "proxy that leaves the bench first should be returned")
+	})
+}
+
+func TestPoolSelection(t *testing.T) {
+	proxies := mustProxies(t, "127.0.0.1:8080", "127.0.0.1:8081", "127.0.0.1:8082")
+
+	t.Run("RoundRobin", func(t *testing.T) {
+		pool := NewPool(proxies)
+		for i := 0; i < 2*len(proxies); i++ {
+			next, err := pool.Next(SelectRoundRobin)
+			assert.NoError(t, err)
+			assert.Equal(t, proxies[i%len(proxies)], next)
+		}
+	})
+
+	t.Run("LeastRecentlyUsed", func(t *testing.T) {
+		var (
+			pool = NewPool(proxies)
+			now  = time.Now()
+		)
+		pool.now = func() time.Time { return now }
+
+		seen := map[*Proxy]bool{}
+		for i := 0; i < len(proxies); i++ {
+			next, _ := pool.Next(SelectLeastRecentlyUsed)
+			seen[next] = true
+			now = now.Add(time.Second)
+		}
+		assert.Len(t, seen, len(proxies), "every proxy should be used once before any is reused")
+	})
+
+	t.Run("Random", func(t *testing.T) {
+		pool := NewPool(proxies)
+		for i := 0; i < errorThreshold; i++ {
+			pool.ReportError(proxies[0])
+		}
+		for i := 0; i < 20; i++ {
+			next, err := pool.Next(SelectRandom)
+			assert.NoError(t, err)
+			assert.NotEqual(t, proxies[0], next, "benched proxies should not be picked")
+		}
+	})
+}
+
+func TestPoolUpdates(t *testing.T) {
+	proxies := mustProxies(t, "127.0.0.1:8080", "127.0.0.1:8081", "127.0.0.1:8082")
+	pool := NewPool(proxies[:2])
+
+	pool.ReportSuccess(proxies[1], time.Second)
+
+	pool.Set(mustProxies(t, "127.0.0.1:8081", "127.0.0.1:8082"))
+	assert.Equal(t, 2, pool.Len())
+	assert.False(t, pool.Contains(proxies[0]))
+	assert.True(t, pool.Contains(proxies[2]))
+	assert.Equal(t, uint64(1), pool.Stats()[0].Successes, "health of kept proxies should be preserved")
+
+	pool.Add(proxies)
+	assert.Equal(t, 3, pool.Len(), "duplicated proxies should be ignored")
+
+	assert.True(t, pool.Remove(proxies[1]))
+	assert.False(t, pool.Remove(proxies[1]), "removing twice should return false")
+	assert.Equal(t, 2, pool.Len())
+
+	pool.Set(nil)
+	_, err := pool.Next(SelectHealthiest)
+	assert.ErrorIs(t, err, ErrNoProxies)
+}
+
+func TestPoolCooldown(t *testing.T) {
+	var (
+		proxies = mustProxies(t, "user:pass@127.0.0.1:8080")
+		pool    = NewPool(proxies)
+		now     = time.Now()
+	)
+	pool.now = func() time.Time { return now }
+
+	for ban := 0; ban < 3; ban++ {
+		for i := 0; i < errorThreshold; i++ {
+			pool.ReportError(proxies[0])
+		}
+		stats := pool.Stats()[0]
+		assert.Equal(t, ban+1, stats.Bans)
+		assert.Equal(t, now.Add(baseCooldown<<ban), stats.BenchedUntil, "cooldown should double on every bench")
+	}
+
+	for i := 0; i < 10*errorThreshold; i++ {
+		pool.ReportError(proxies[0])
+	}
+	assert.Equal(t, now.Add(maxCooldown), pool.Stats()[0].BenchedUntil, "cooldown should be capped")
+
+	pool.ReportSuccess(proxies[0], time.Second)
+	stats := pool.Stats()[0]
+	assert.Equal(t, 0, stats.Bans, "success should reset the bans")
+	assert.Equal(t, "127.0.0.1:8080", stats.Proxy, "stats should not expose credentials")
+	assert.Equal(t, time.Second, stats.AverageLatency)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/url"
 	"strings"
 	"sync"
@@ -33,8 +34,13 @@ type Monitor struct {
 	buildIdUpdateLock     *sync.Mutex
 	startStopLock         *sync.Mutex
 	delay                 time.Duration
-	proxies               []*proxy.Proxy
-	curProxyIndex         *atomic.Uint64
+	proxies               *proxy.Groups
+	rotation              RotationStrategy
+	localAddrs            []net.IP
+	curLocalAddrIndex     *atomic.Uint64
+	maxTasksPerEgress     int
+	dedupe                DedupeOptions
+	suppressed            *dedupeCounters
 	//logger              log.Logger
 }
 
@@ -49,19 +55,31 @@ type SizeInfo struct {
 }
 
 type RestockInfo struct {
-	Path     string // Url of the restocked product
-	Name     string
-	NickName string
-	Code     string
-	Price    string
-	Picture  string
-	Sizes    []*SizeInfo // List of products that have stock or are available (not just the ones that just restocked)
+	Path       string // Url of the restocked product
+	Name       string
+	NickName   string
+	Code       string
+	Price      string
+	Picture    string
+	Sizes      []*SizeInfo // List of products that have stock or are available (not just the ones that just restocked)
+	TaskID     string      // TaskID is the id returned by AddTask for the task being notified
+	DetectedAt time.Time   // DetectedAt is when the restock was found
 }
 
 type monitorTask struct {
 	path     string
 	callback chan RestockInfo
 	id       string
+	options  taskOptions
+	result   chan error
+}
+
+// proxyGroups returns the proxy groups of the task in order of preference, the default one if it didn't select any
+func (t monitorTask) proxyGroups() []string {
+	if len(t.options.proxyGroups) == 0 {
+		return defaultProxyGroups
+	}
+	return t.options.proxyGroups
 }
 
 var (
@@ -90,20 +108,16 @@ var (
 	errNoProxiesAvailable    = errors.New("no proxies available")
 	ErrInvalidUrl            = errors.New("invalid URL")
 	ErrNilCallback           = errors.New("nil callback")
+	ErrUnknownProxy          = errors.New("proxy is not in use by the monitor")
+	ErrUnknownProxyGroup     = errors.New("unknown proxy group")
 )
 
-func (m *Monitor) getProxy() (string, error) {
-	if len(m.proxies) > 0 {
-		return m.proxies[m.curProxyIndex.Inc()%uint64(len(m.proxies))].String(), nil
-	}
-	return "", errNoProxiesAvailable
-}
-
 // NewMonitor is used to create and initialize a new Monitor struct with sane defaults and error checking.
 // It takes as input a userAgent string representing the user agent to be used when making requests to the website,
 // a delay duration representing the amount of time to wait between requests,
-// a slice of proxies containing the proxy URLs to be used for requests, and a *mimic.ClientSpec to configure the http clients.
-func NewMonitor(userAgent string, delay time.Duration, proxies []string, mimicSpec *mimic.ClientSpec) (*Monitor, error) {
+// a slice of proxies containing the proxy URLs to be used for requests, a *mimic.ClientSpec to configure the http clients
+// and optional Options.
+func NewMonitor(userAgent string, delay time.Duration, proxies []string, mimicSpec *mimic.ClientSpec, opts ...Option) (*Monitor, error) {
 	if userAgent == "" {
 		return nil, ErrInvalidUserAgent
 	}
@@ -116,14 +130,9 @@ func NewMonitor(userAgent string, delay time.Duration, proxies []string, mimicSp
 		return nil, errors.New("delay too low")
 	}
 
-	var parsedProxies []*proxy.Proxy
-
-	for _, rawProxy := range proxies {
-		if parsed, err := proxy.FromString(rawProxy); err == nil {
-			parsedProxies = append(parsedProxies, parsed)
-		} else {
-			return nil, err
-		}
+	parsedProxies, err := parseProxies(proxies)
+	if err != nil {
+		return nil, err
 	}
 
 	monitor := Monitor{
@@ -139,26 +148,40 @@ func NewMonitor(userAgent string, delay time.Duration, proxies []string, mimicSp
 		buildIdUpdateLock:     &sync.Mutex{},
 		startStopLock:         &sync.Mutex{},
 		delay:                 delay,
-		proxies:               parsedProxies,
-		curProxyIndex:         &atomic.Uint64{},
+		proxies:               proxy.NewGroups(),
+		curLocalAddrIndex:     &atomic.Uint64{},
+		maxTasksPerEgress:     defaultMaxTasksPerEgress,
+		suppressed:            &dedupeCounters{},
+	}
+
+	monitor.proxies.Set(proxy.DefaultGroup, parsedProxies)
+
+	for _, opt := range opts {
+		if err := opt(&monitor); err != nil {
+			return nil, err
+		}
 	}
 
-	monitor.defaultClient = monitor.newHttpClient()
+	monitor.defaultClient, _ = monitor.newHttpClient(proxy.SelectHealthiest, defaultProxyGroups)
 
 	return &monitor, nil
 }
 
-func (m *Monitor) newHttpClient() *http.Client {
+// newHttpClient returns a new client and the proxy it uses, which is nil when no proxies are available
+func (m *Monitor) newHttpClient(sel proxy.Selection, groups []string) (*http.Client, *proxy.Proxy) {
 	//This function never returns an err != nil (checked on source code)
 	jar, _ := cookiejar.New(nil)
 	var newClient *http.Client
-	if proxy, err := m.getProxy(); err == nil {
-		proxyUrl, _ := url.Parse(proxy)
-		newClient = &http.Client{Jar: jar, Transport: m.mimicSpec.ConfigureTransport(&http.Transport{Proxy: http.ProxyURL(proxyUrl)}), Timeout: 20 * time.Second}
+	clientProxy, err := m.getProxy(sel, groups)
+	if err == nil {
+		newClient = &http.Client{Jar: jar, Transport: m.mimicSpec.ConfigureTransport(&http.Transport{Proxy: http.ProxyURL(clientProxy.URL)}), Timeout: 20 * time.Second}
+	} else if localAddr := m.nextLocalAddr(); localAddr != nil {
+		transport := &http.Transport{DialContext: localDialer(localAddr).DialContext}
+		newClient = &http.Client{Jar: jar, Transport: m.mimicSpec.ConfigureTransport(transport), Timeout: 20 * time.Second}
 	} else {
 		newClient = &http.Client{Jar: jar, Transport: m.mimicSpec.ConfigureTransport(&http.Transport{}), Timeout: 20 * time.Second}
 	}
-	return newClient
+	return newClient, clientProxy
 }
 
 func (m *Monitor) generateMonitorUrl(path string) string {
@@ -213,15 +236,33 @@ func (m *Monitor) performGet(client *http.Client, url string) (body []byte, stat
 
 }
 
-func (m *Monitor) monitorProduct(cancel <-chan struct{}, productPath string, notify chan<- RestockInfo) {
+func (m *Monitor) monitorProduct(cancel <-chan struct{}, task monitorTask, notify chan<- RestockInfo) {
 	var (
+		productPath          = task.path
+		rotation             = m.rotation
 		backendUrl           = m.generateMonitorUrl(productPath)
-		localClient          = m.newHttpClient()
-		previouslyAvailable  = map[string]bool{}
-		previouslyInStock    = map[string]bool{}
+		tracker              = newSizeTracker(m.dedupe.MinStablePolls, &m.suppressed.unstable)
 		lastRequestStartTime = time.Now().Add(-m.delay)
 	)
 
+	if task.options.rotation != nil {
+		rotation = *task.options.rotation
+	}
+
+	groups := task.proxyGroups()
+
+	var (
+		rotator                 = newRotator(rotation)
+		localClient, localProxy = m.newHttpClient(rotation.selection(), groups)
+	)
+
+	rotate := func() {
+		// Old transports would otherwise keep their idle connections open forever
+		localClient.CloseIdleConnections()
+		localClient, localProxy = m.newHttpClient(rotation.selection(), groups)
+		rotator.reset()
+	}
+
 	for {
 		select {
 		case <-cancel:
@@ -233,9 +274,18 @@ func (m *Monitor) monitorProduct(cancel <-chan struct{}, productPath string, not
 
 		lastRequestStartTime = time.Now()
 
+		if rotator.due() || m.staleProxy(localProxy, groups) {
+			rotate()
+		}
+
+		rotator.used()
 		body, statusCode, err := m.performGet(localClient, backendUrl)
 
 		if err != nil {
+			// Only rotate once the proxy gets benched, a single timeout doesn't mean the proxy is dead
+			if m.proxies.ReportError(localProxy) {
+				rotate()
+			}
 			continue
 		}
 
@@ -247,21 +297,16 @@ func (m *Monitor) monitorProduct(cancel <-chan struct{}, productPath string, not
 
 		switch statusCode {
 		case http.StatusOK:
+			m.proxies.ReportSuccess(localProxy, time.Since(lastRequestStartTime))
 			if !gjson.Valid(jsonString) {
 				continue
 			}
 			product := gjson.Get(jsonString, "pageProps.product")
 			sizes := product.Get("sizes").Array()
 			for _, size := range sizes {
-				thisSize := &SizeInfo{
-					Description: size.Get("description").String(),
-					Sku:         size.Get("sku").String(),
-					Ean:         size.Get("ean").String(),
-					HasStock:    size.Get("hasStock").Bool(),
-					IsAvailable: size.Get("isAvailable").Bool(),
-				}
+				thisSize := newSizeInfo(size)
 				// Checks if it was previously not in stock but is now, or if it was not available but is now. In stock means what it says, but it can only be added to cart when it is Available
-				if thisSize.IsAvailable && !previouslyAvailable[thisSize.Sku] || thisSize.HasStock && !previouslyInStock[thisSize.Sku] {
+				if tracker.observe(thisSize.Sku, sizeState{available: thisSize.IsAvailable, inStock: thisSize.HasStock}) {
 					thisSize.Restocked = true
 					hadRestock = true
 				}
@@ -269,24 +314,14 @@ func (m *Monitor) monitorProduct(cancel <-chan struct{}, productPath string, not
 				if thisSize.IsAvailable || thisSize.HasStock {
 					products = append(products, thisSize)
 				}
-
-				previouslyAvailable[thisSize.Sku] = thisSize.IsAvailable
-				previouslyInStock[thisSize.Sku] = thisSize.HasStock
 			}
 
 			if hadRestock {
-				notify <- RestockInfo{
-					Path:     productPath,
-					Name:     product.Get("name").String(),
-					NickName: product.Get("nickname").String(),
-					Code:     product.Get("colorInfo.styleCode").String(),
-					Price:    product.Get("priceInfos.priceFormatted").String(),
-					Picture:  product.Get("images.0.url").String(),
-					Sizes:    products,
-				}
+				notify <- newRestockInfo(productPath, product, products)
 			}
 		case http.StatusForbidden:
-			localClient = m.newHttpClient()
+			m.proxies.ReportForbidden(localProxy)
+			rotate()
 		case http.StatusNotFound:
 			m.updateBuildID()
 			backendUrl = m.generateMonitorUrl(productPath)
@@ -295,6 +330,78 @@ func (m *Monitor) monitorProduct(cancel <-chan struct{}, productPath string, not
 	}
 }
 
+// newSizeInfo reads a size of the product JSON
+func newSizeInfo(size gjson.Result) *SizeInfo {
+	return &SizeInfo{
+		Description: size.Get("description").String(),
+		Sku:         size.Get("sku").String(),
+		Ean:         size.Get("ean").String(),
+		HasStock:    size.Get("hasStock").Bool(),
+		IsAvailable: size.Get("isAvailable").Bool(),
+	}
+}
+
+// newRestockInfo reads the product JSON of path, sizes are the ones already read that should be notified
+func newRestockInfo(path string, product gjson.Result, sizes []*SizeInfo) RestockInfo {
+	return RestockInfo{
+		Path:       path,
+		Name:       product.Get("name").String(),
+		NickName:   product.Get("nickname").String(),
+		Code:       product.Get("colorInfo.styleCode").String(),
+		Price:      product.Get("priceInfos.priceFormatted").String(),
+		Picture:    product.Get("images.0.url").String(),
+		Sizes:      sizes,
+		DetectedAt: time.Now(),
+	}
+}
+
+// parseProduct reads the product JSON of path with its sizes in stock or available
+func parseProduct(path string, product gjson.Result) RestockInfo {
+	var sizes []*SizeInfo
+	for _, size := range product.Get("sizes").Array() {
+		if info := newSizeInfo(size); info.HasStock || info.IsAvailable {
+			sizes = append(sizes, info)
+		}
+	}
+	return newRestockInfo(path, product, sizes)
+}
+
+// FetchProduct gets the current state of a product once, with the sizes in stock or available and none flagged as restocked.
+// The monitor doesn't need to be started, it's meant for testing notifications with real data
+func (m *Monitor) FetchProduct(productUrl string) (RestockInfo, error) {
+	parsed, err := ParseNKUrl(productUrl)
+	if err != nil {
+		return RestockInfo{}, err
+	}
+
+	if m.buildID.Load() == "" {
+		if err := m.updateBuildID(); err != nil && !errors.Is(err, errBuildIDAlreadyUpdated) {
+			return RestockInfo{}, err
+		}
+	}
+
+	body, statusCode, err := m.performGet(m.defaultClient, m.generateMonitorUrl(parsed.Path))
+	if err != nil {
+		return RestockInfo{}, err
+	}
+
+	if statusCode != http.StatusOK {
+		return RestockInfo{}, fmt.Errorf("fetching product: HTTP status %v", statusCode)
+	}
+
+	jsonString := string(body)
+	if !gjson.Valid(jsonString) {
+		return RestockInfo{}, errInvalidJson
+	}
+
+	product := gjson.Get(jsonString, "pageProps.product")
+	if !product.Exists() {
+		return RestockInfo{}, errors.New("fetching product: no product in the response")
+	}
+
+	return parseProduct(parsed.Path, product), nil
+}
+
 func ParseNKUrl(productUrl string) (*url.URL, error) {
 	parsed, err := url.Parse(productUrl)
 	if err != nil {
@@ -308,8 +415,10 @@ func ParseNKUrl(productUrl string) (*url.URL, error) {
 }
 
 // AddTask creates a new monitoring task for the desired url and callback channel, returns the uuid of the task
-// so it can be stopped later with RemoveTask
-func (m *Monitor) AddTask(productUrl string, callback chan RestockInfo) (string, error) {
+// so it can be stopped later with RemoveTask.
+// Returns ErrTooManyTasks if the product is not monitored yet and the limit of tasks per egress identity was reached,
+// and ErrUnknownProxyGroup if the task selects a proxy group that was never configured.
+func (m *Monitor) AddTask(productUrl string, callback chan RestockInfo, opts ...TaskOption) (string, error) {
 	if !m.started.Load() {
 		return "", ErrNotStarted
 	}
@@ -322,8 +431,21 @@ func (m *Monitor) AddTask(productUrl string, callback chan RestockInfo) (string,
 	if err != nil {
 		return "", err
 	}
-	newTask := monitorTask{path: parsed.Path, callback: callback, id: uuid.NewString()}
+	newTask := monitorTask{path: parsed.Path, callback: callback, id: uuid.NewString(), result: make(chan error, 1)}
+	for _, opt := range opts {
+		if err := opt(&newTask.options); err != nil {
+			return "", err
+		}
+	}
+	for _, group := range newTask.options.proxyGroups {
+		if !m.proxies.Has(group) {
+			return "", fmt.Errorf("%w: %s", ErrUnknownProxyGroup, group)
+		}
+	}
 	m.addTaskCh <- newTask
+	if err := <-newTask.result; err != nil {
+		return "", err
+	}
 	return newTask.id, nil
 
 }
@@ -340,24 +462,36 @@ func (m *Monitor) mainLoop() {
 	var (
 		updateNotifyCh = make(chan RestockInfo, 1)
 		taskList       = make(map[string]map[string]monitorTask)
+		productGroups  = make(map[string][]string) // productGroups are the proxy groups each product is monitored with
 		cancelChs      = make(map[string]chan struct{})
+		dedupe         = newDeduper(m.dedupe, m.suppressed)
 	)
 
 	for {
 		select {
 		case newTask := <-m.addTaskCh:
 			if _, ok := taskList[newTask.path]; !ok {
+				if err := m.checkTaskLimit(newTask.proxyGroups(), productGroups); err != nil {
+					newTask.result <- err
+					continue
+				}
 				cancelChannel := make(chan struct{}, 1)
-				go m.monitorProduct(cancelChannel, newTask.path, updateNotifyCh)
+				go m.monitorProduct(cancelChannel, newTask, updateNotifyCh)
 				taskList[newTask.path] = map[string]monitorTask{}
+				productGroups[newTask.path] = newTask.proxyGroups()
 				cancelChs[newTask.path] = cancelChannel
 			}
 			taskList[newTask.path][newTask.id] = newTask
+			newTask.result <- nil
 		case restockInfo := <-updateNotifyCh:
+			if !dedupe.filter(&restockInfo) {
+				continue
+			}
 			for _, task := range taskList[restockInfo.Path] {
 				//copy to avoid race conditions
 				callback := task.callback
 				info := restockInfo
+				info.TaskID = task.id
 				go func() {
 					//Use a timeout to avoid a coroutine leak if the value is never received by the receiver
 					select {
@@ -377,6 +511,7 @@ func (m *Monitor) mainLoop() {
 					cancelChs[key] <- struct{}{}
 					// This is safe https://stackoverflow.com/questions/23229975/is-it-safe-to-remove-selected-keys-from-map-within-a-range-loop
 					delete(taskList, key)
+					delete(productGroups, key)
 					delete(cancelChs, key)
 				}
 			}
@@ -464,7 +599,7 @@ func (m *Monitor) updateBuildID() error {
 		return nil
 
 	case http.StatusForbidden:
-		m.defaultClient = m.newHttpClient()
+		m.defaultClient, _ = m.newHttpClient(proxy.SelectHealthiest, defaultProxyGroups)
 		fallthrough
 
 	default:
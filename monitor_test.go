@@ -6,6 +6,7 @@ import (
 
 	"github.com/saucesteals/mimic"
 	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
 	"go.uber.org/atomic"
 )
 
@@ -44,3 +45,60 @@ func TestMonitor(t *testing.T) {
 	}
 
 }
+
+func TestMonitorProxies(t *testing.T) {
+	m, _ := mimic.Chromium(mimic.BrandChrome, "106.0.0.0")
+	monitor, err := NewMonitor("not empty", time.Second, []string{"127.0.0.1:8080"}, m)
+	assert.NoError(t, err)
+
+	assert.Error(t, monitor.SetProxies([]string{"127.0.0.1:8080", "invalid"}), "invalid proxies should be rejected")
+	assert.Len(t, monitor.ProxyStats(), 1, "proxies should be kept when the update is invalid")
+
+	assert.NoError(t, monitor.AddProxies([]string{"127.0.0.1:8081"}))
+	assert.Len(t, monitor.ProxyStats(), 2)
+
+	assert.NoError(t, monitor.RemoveProxy("127.0.0.1:8080"))
+	assert.ErrorIs(t, monitor.RemoveProxy("127.0.0.1:8080"), ErrUnknownProxy)
+	assert.Len(t, monitor.ProxyStats(), 1)
+
+	assert.NoError(t, monitor.SetProxies(nil))
+	assert.Empty(t, monitor.ProxyStats())
+
+	monitor.started.Store(true)
+	defer monitor.started.Store(false)
+	_, err = monitor.AddTask(validUrl, make(chan RestockInfo), WithTaskProxyGroups("unknown"))
+	assert.ErrorIs(t, err, ErrUnknownProxyGroup, "tasks should only select groups that were configured")
+	assert.Empty(t, monitor.ProxyStats(), "selecting a group should not create it")
+}
+
+func TestParseProduct(t *testing.T) {
+	product := gjson.Get(`{"pageProps": {"product": {
+		"name": "Jacket", "nickname": "Jkt", "colorInfo": {"styleCode": "AB1234-001"},
+		"priceInfos": {"priceFormatted": "R$ 499,99"}, "images": [{"url": "https://example.com/1.jpg"}],
+		"sizes": [
+			{"description": "P", "sku": "1", "ean": "11", "hasStock": true, "isAvailable": true},
+			{"description": "M", "sku": "2", "ean": "22", "hasStock": true, "isAvailable": false},
+			{"description": "G", "sku": "3", "ean": "33", "hasStock": false, "isAvailable": false}
+		]
+	}}}`, "pageProps.product")
+
+	info := parseProduct("/snkrs/jacket-024491.html", product)
+	assert.Equal(t, "/snkrs/jacket-024491.html", info.Path)
+	assert.Equal(t, "Jacket", info.Name)
+	assert.Equal(t, "Jkt", info.NickName)
+	assert.Equal(t, "AB1234-001", info.Code)
+	assert.Equal(t, "R$ 499,99", info.Price)
+	assert.Equal(t, "https://example.com/1.jpg", info.Picture)
+	assert.Equal(t, []*SizeInfo{
+		{Description: "P", Sku: "1", Ean: "11", HasStock: true, IsAvailable: true},
+		{Description: "M", Sku: "2", Ean: "22", HasStock: true},
+	}, info.Sizes, "sizes out of stock should be left out and none flagged as restocked")
+
+	m, _ := mimic.Chromium(mimic.BrandChrome, "106.0.0.0")
+	monitor, err := NewMonitor("not empty", time.Second, nil, m)
+	assert.NoError(t, err)
+	for _, test := range invalidUrls {
+		_, err = monitor.FetchProduct(test.url)
+		assert.ErrorIsf(t, err, ErrInvalidUrl, "invalid url should return errInvalidUrl, url: %s, reason: %s", test.url, test.reason)
+	}
+}
@@ -0,0 +1,94 @@
+package nkmonitor
+
+import "errors"
+
+// Option configures a Monitor, see NewMonitor
+type Option func(*Monitor) error
+
+// TaskOption configures a single task, see AddTask.
+// Tasks for the same product share the same requests, so the options of the first task added for a product are the ones used.
+type TaskOption func(*taskOptions) error
+
+type taskOptions struct {
+	rotation    *RotationStrategy
+	proxyGroups []string
+}
+
+// WithRotation sets the default rotation strategy of every task, the default is sticky until failure
+func WithRotation(strategy RotationStrategy) Option {
+	return func(m *Monitor) error {
+		if err := strategy.validate(); err != nil {
+			return err
+		}
+		m.rotation = strategy
+		return nil
+	}
+}
+
+// WithTaskRotation overrides the monitor's rotation strategy for a task
+func WithTaskRotation(strategy RotationStrategy) TaskOption {
+	return func(o *taskOptions) error {
+		if err := strategy.validate(); err != nil {
+			return err
+		}
+		o.rotation = &strategy
+		return nil
+	}
+}
+
+// WithProxyGroup adds a group of proxies to the monitor, tasks select it with WithTaskProxyGroups
+func WithProxyGroup(group string, proxies []string) Option {
+	return func(m *Monitor) error {
+		return m.AddGroupProxies(group, proxies)
+	}
+}
+
+// WithTaskProxyGroups selects the proxy groups a task draws its proxies from, in order of preference.
+// The next group is used when every proxy of a group is benched or the group is empty, tasks use the default group otherwise.
+// Every group must have been configured with WithProxyGroup, SetGroupProxies or AddGroupProxies before the task is added.
+func WithTaskProxyGroups(groups ...string) TaskOption {
+	return func(o *taskOptions) error {
+		for _, group := range groups {
+			if group == "" {
+				return errEmptyGroupName
+			}
+		}
+		o.proxyGroups = groups
+		return nil
+	}
+}
+
+// WithLocalAddrs binds outgoing connections of clients that don't use a proxy to the given local IPs or network interfaces,
+// used in round-robin order
+func WithLocalAddrs(addrs []string) Option {
+	return func(m *Monitor) error {
+		for _, addr := range addrs {
+			ip, err := resolveLocalAddr(addr)
+			if err != nil {
+				return err
+			}
+			m.localAddrs = append(m.localAddrs, ip)
+		}
+		return nil
+	}
+}
+
+// WithMaxTasksPerEgress sets how many products can be monitored per egress identity (proxy, local address or the host itself),
+// AddTask returns ErrTooManyTasks when going over it. The default is 5.
+func WithMaxTasksPerEgress(n int) Option {
+	return func(m *Monitor) error {
+		if n < 1 {
+			return errors.New("max tasks per egress must be at least 1")
+		}
+		m.maxTasksPerEgress = n
+		return nil
+	}
+}
+
+// WithoutTaskLimit disables the limit of tasks per egress identity, use at your own risk
+func WithoutTaskLimit() Option {
+	return func(m *Monitor) error {
+		m.maxTasksPerEgress = 0
+		return nil
+	}
+}
@@ -0,0 +1,104 @@
+package nkmonitor
+
+import (
+	"errors"
+
+	"github.com/rodjunger/nkmonitor/internal/proxy"
+)
+
+// DefaultProxyGroup is the group of the proxies given to NewMonitor and used by tasks that don't select a group
+const DefaultProxyGroup = proxy.DefaultGroup
+
+// ProxyStats is a snapshot of the health of one of the monitor's proxies
+type ProxyStats = proxy.Stats
+
+var (
+	defaultProxyGroups = []string{DefaultProxyGroup}
+	errEmptyGroupName  = errors.New("empty proxy group name")
+)
+
+func (m *Monitor) getProxy(sel proxy.Selection, groups []string) (*proxy.Proxy, error) {
+	next, err := m.proxies.Next(groups, sel)
+	if err != nil {
+		return nil, errNoProxiesAvailable
+	}
+	return next, nil
+}
+
+// staleProxy reports whether a client using p should be rotated because the proxy groups changed,
+// that is when p was removed or when proxies became available for a client that is not using one
+func (m *Monitor) staleProxy(p *proxy.Proxy, groups []string) bool {
+	if p == nil {
+		return m.proxies.Len(groups) > 0
+	}
+	return !m.proxies.Contains(groups, p)
+}
+
+func parseProxies(proxies []string) ([]*proxy.Proxy, error) {
+	var parsedProxies []*proxy.Proxy
+
+	for _, rawProxy := range proxies {
+		if parsed, err := proxy.FromString(rawProxy); err == nil {
+			parsedProxies = append(parsedProxies, parsed)
+		} else {
+			return nil, err
+		}
+	}
+
+	return parsedProxies, nil
+}
+
+// SetProxies replaces the proxies of the default group, it's safe to call while tasks are running.
+// Tasks using a proxy that was removed switch to a new one before their next request.
+func (m *Monitor) SetProxies(proxies []string) error {
+	return m.SetGroupProxies(DefaultProxyGroup, proxies)
+}
+
+// AddProxies adds proxies to the default group, it's safe to call while tasks are running
+func (m *Monitor) AddProxies(proxies []string) error {
+	return m.AddGroupProxies(DefaultProxyGroup, proxies)
+}
+
+// SetGroupProxies replaces the proxies of a group, creating it if needed. It's safe to call while tasks are running.
+func (m *Monitor) SetGroupProxies(group string, proxies []string) error {
+	if group == "" {
+		return errEmptyGroupName
+	}
+	parsed, err := parseProxies(proxies)
+	if err != nil {
+		return err
+	}
+	m.proxies.Set(group, parsed)
+	return nil
+}
+
+// AddGroupProxies adds proxies to a group, creating it if needed. It's safe to call while tasks are running.
+func (m *Monitor) AddGroupProxies(group string, proxies []string) error {
+	if group == "" {
+		return errEmptyGroupName
+	}
+	parsed, err := parseProxies(proxies)
+	if err != nil {
+		return err
+	}
+	m.proxies.Add(group, parsed)
+	return nil
+}
+
+// RemoveProxy stops the monitor from using a proxy in any group, it's safe to call while tasks are running.
+// Returns ErrUnknownProxy if the proxy is not in use.
+func (m *Monitor) RemoveProxy(rawProxy string) error {
+	parsed, err := proxy.FromString(rawProxy)
+	if err != nil {
+		return err
+	}
+	if !m.proxies.Remove(parsed) {
+		return ErrUnknownProxy
+	}
+	return nil
+}
+
+// ProxyStats returns the health of every proxy used by the monitor, benched proxies are not used until their cooldown ends
+func (m *Monitor) ProxyStats() []ProxyStats {
+	return m.proxies.Stats()
+}
@@ -0,0 +1,132 @@
+package nkmonitor
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rodjunger/nkmonitor/internal/proxy"
+)
+
+// RotationKind defines when a task switches to a new client (and thus a new proxy and cookie jar).
+// Regardless of the kind, tasks always rotate after a 403 or when their proxy gets benched.
+type RotationKind int
+
+const (
+	// RotateStickyUntilFailure keeps the same proxy until it fails, preferring healthy proxies when rotating
+	RotateStickyUntilFailure RotationKind = iota
+	// RotateRoundRobin uses a new proxy for every request, in order
+	RotateRoundRobin
+	// RotateRandom uses a new random proxy for every request
+	RotateRandom
+	// RotateLeastRecentlyUsed uses the proxy that was used the longest time ago for every request
+	RotateLeastRecentlyUsed
+	// RotateEveryRequests rotates after RotationStrategy.Requests requests
+	RotateEveryRequests
+	// RotateEveryDuration rotates after RotationStrategy.Interval has passed
+	RotateEveryDuration
+)
+
+var rotationKindNames = map[RotationKind]string{
+	RotateStickyUntilFailure: "sticky",
+	RotateRoundRobin:         "round-robin",
+	RotateRandom:             "random",
+	RotateLeastRecentlyUsed:  "lru",
+	RotateEveryRequests:      "every-n",
+	RotateEveryDuration:      "every-duration",
+}
+
+var ErrInvalidRotation = errors.New("invalid rotation strategy")
+
+func (k RotationKind) String() string {
+	if name, ok := rotationKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("RotationKind(%d)", int(k))
+}
+
+// ParseRotationKind converts a name as returned by RotationKind.String back to a RotationKind
+func ParseRotationKind(name string) (RotationKind, error) {
+	for kind, kindName := range rotationKindNames {
+		if kindName == name {
+			return kind, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown kind %q", ErrInvalidRotation, name)
+}
+
+// RotationStrategy trades cookie continuity against ban risk, the zero value is sticky until failure
+type RotationStrategy struct {
+	Kind     RotationKind
+	Requests int           // Requests is the amount of requests between rotations, used by RotateEveryRequests
+	Interval time.Duration // Interval is the time between rotations, used by RotateEveryDuration
+}
+
+func (s RotationStrategy) validate() error {
+	switch s.Kind {
+	case RotateStickyUntilFailure, RotateRoundRobin, RotateRandom, RotateLeastRecentlyUsed:
+		return nil
+	case RotateEveryRequests:
+		if s.Requests < 1 {
+			return fmt.Errorf("%w: requests must be at least 1", ErrInvalidRotation)
+		}
+		return nil
+	case RotateEveryDuration:
+		if s.Interval <= 0 {
+			return fmt.Errorf("%w: interval must be positive", ErrInvalidRotation)
+		}
+		return nil
+	default:
+		return ErrInvalidRotation
+	}
+}
+
+// selection maps the strategy to the way the proxy pool picks the next proxy
+func (s RotationStrategy) selection() proxy.Selection {
+	switch s.Kind {
+	case RotateRoundRobin:
+		return proxy.SelectRoundRobin
+	case RotateRandom:
+		return proxy.SelectRandom
+	case RotateLeastRecentlyUsed:
+		return proxy.SelectLeastRecentlyUsed
+	default:
+		return proxy.SelectHealthiest
+	}
+}
+
+// rotator keeps track of when a task last rotated its client
+type rotator struct {
+	strategy RotationStrategy
+	requests int
+	since    time.Time
+}
+
+func newRotator(strategy RotationStrategy) *rotator {
+	return &rotator{strategy: strategy, since: time.Now()}
+}
+
+// due is called before every request and returns whether the client should be rotated first
+func (r *rotator) due() bool {
+	switch r.strategy.Kind {
+	case RotateRoundRobin, RotateRandom, RotateLeastRecentlyUsed:
+		return r.requests >= 1
+	case RotateEveryRequests:
+		return r.requests >= r.strategy.Requests
+	case RotateEveryDuration:
+		return time.Since(r.since) >= r.strategy.Interval
+	default:
+		return false
+	}
+}
+
+// used records a request made with the current client
+func (r *rotator) used() {
+	r.requests++
+}
+
+// reset is called every time the client is rotated, for whatever reason
+func (r *rotator) reset() {
+	r.requests = 0
+	r.since = time.Now()
+}
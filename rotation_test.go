@@ -0,0 +1,63 @@
+package nkmonitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRotationKind(t *testing.T) {
+	for kind, name := range rotationKindNames {
+		parsed, err := ParseRotationKind(name)
+		assert.NoError(t, err)
+		assert.Equal(t, kind, parsed)
+		assert.Equal(t, name, kind.String())
+	}
+
+	_, err := ParseRotationKind("sometimes")
+	assert.ErrorIs(t, err, ErrInvalidRotation)
+}
+
+func TestRotationStrategyValidate(t *testing.T) {
+	assert.NoError(t, RotationStrategy{}.validate(), "zero value should be valid")
+	assert.ErrorIs(t, RotationStrategy{Kind: RotateEveryRequests}.validate(), ErrInvalidRotation)
+	assert.ErrorIs(t, RotationStrategy{Kind: RotateEveryDuration}.validate(), ErrInvalidRotation)
+	assert.ErrorIs(t, RotationStrategy{Kind: RotationKind(99)}.validate(), ErrInvalidRotation)
+	assert.NoError(t, RotationStrategy{Kind: RotateEveryRequests, Requests: 3}.validate())
+}
+
+func TestRotator(t *testing.T) {
+	t.Run("Sticky", func(t *testing.T) {
+		r := newRotator(RotationStrategy{})
+		for i := 0; i < 100; i++ {
+			assert.False(t, r.due())
+			r.used()
+		}
+	})
+
+	t.Run("EveryRequest", func(t *testing.T) {
+		r := newRotator(RotationStrategy{Kind: RotateRandom})
+		assert.False(t, r.due(), "first request should use the initial client")
+		r.used()
+		assert.True(t, r.due())
+		r.reset()
+		assert.False(t, r.due())
+	})
+
+	t.Run("EveryNRequests", func(t *testing.T) {
+		r := newRotator(RotationStrategy{Kind: RotateEveryRequests, Requests: 3})
+		for i := 0; i < 3; i++ {
+			assert.False(t, r.due())
+			r.used()
+		}
+		assert.True(t, r.due())
+	})
+
+	t.Run("EveryDuration", func(t *testing.T) {
+		r := newRotator(RotationStrategy{Kind: RotateEveryDuration, Interval: time.Minute})
+		assert.False(t, r.due())
+		r.since = time.Now().Add(-time.Minute)
+		assert.True(t, r.due())
+	})
+}